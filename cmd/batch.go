@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func batchCmd() *cobra.Command {
+	var workers int
+	var cachePath string
+	var force bool
+
+	var cmdBatch = &cobra.Command{
+		Use:   "batch [manifest]",
+		Short: "Run the conversions listed in a manifest",
+		Long:  `Run the conversions listed in a JSON manifest, tojen.json by default, in parallel. Every entry has an input, an output and the options of tojen gen. Entries whose input, options and tojen version have not changed since the last run are skipped using a cache file next to the manifest.`,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := "tojen.json"
+			if len(args) == 1 {
+				path = args[0]
+			}
+			m, err := gen.ReadManifest(path)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if cachePath == "" {
+				cachePath = filepath.Join(filepath.Dir(path), ".tojen-cache.json")
+			}
+			cache := gen.BatchCache{}
+			if !force {
+				cache, err = gen.ReadBatchCache(cachePath)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+			failed, skipped := 0, 0
+			for _, r := range gen.RunBatch(m, cache, workers) {
+				switch {
+				case r.Err != nil:
+					failed++
+					fmt.Println(r.Err)
+				case r.Skipped:
+					skipped++
+				default:
+					fmt.Println("Successfuly wrote file to " + r.Entry.Output)
+				}
+			}
+			err = cache.Write(cachePath)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Printf("%d converted, %d unchanged, %d failed\n", len(m.Entries)-skipped-failed, skipped, failed)
+			if failed > 0 {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		},
+	}
+	cmdBatch.Flags().IntVarP(&workers, "jobs", "j", runtime.NumCPU(), "Number of conversions to run at the same time")
+	cmdBatch.Flags().StringVar(&cachePath, "cache", "", "Path of the cache file, .tojen-cache.json next to the manifest by default")
+	cmdBatch.Flags().BoolVar(&force, "force", false, "Convert every entry, ignoring the cache")
+	return cmdBatch
+}
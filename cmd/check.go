@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func checkCmd() *cobra.Command {
+	var tags []string
+
+	var cmdCheck = &cobra.Command{
+		Use:   "check [path to file or package] [generator.go]",
+		Short: "Check that a generator still reproduces its source",
+		Long:  `Run a committed generator and compare the code it renders to the source it was generated from, a .go file, a .txtar archive or a package. Comments and formatting are ignored. On drift a unified diff from the source to the rendered code is printed and the exit status is 1.`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			applyConfig(cmd, args[0])
+			files, err := gen.BatchEntry{Input: args[0], Tags: tags}.Read()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			generator, err := ioutil.ReadFile(args[1])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			diff, err := gen.Check(args[1], generator, args[0], files)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if diff != "" {
+				fmt.Print(diff)
+				os.Exit(1)
+			}
+			fmt.Println("ok " + args[1])
+			os.Exit(0)
+		},
+	}
+	cmdCheck.Flags().StringSliceVar(&tags, "tags", nil, "Build tags selecting the files of a package")
+	return cmdCheck
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+// pathFlags are the flags holding paths, which are relative to the
+// configuration file when set in it
+var pathFlags = map[string]bool{"output": true, "out-dir": true, "into": true, "base": true}
+
+// applyConfig sets the flags of cmd that are not set on the command line to
+// the values of the configuration file found from the input. The package of
+// the configuration only replaces the main fallback, not $GOPACKAGE.
+func applyConfig(cmd *cobra.Command, input string) {
+	dir := "."
+	if info, err := os.Stat(input); err == nil {
+		dir = input
+		if !info.IsDir() {
+			dir = filepath.Dir(input)
+		}
+	}
+	c, err := gen.FindConfig(dir)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if c == nil {
+		return
+	}
+	for _, key := range c.Keys() {
+		if !knownFlag(cmd.Root(), key) {
+			fmt.Printf("%s: unknown key %s\n", c.Path, key)
+			os.Exit(1)
+		}
+		f := cmd.Flags().Lookup(key)
+		if f == nil || f.Changed || (key == "package" && os.Getenv("GOPACKAGE") != "") {
+			continue
+		}
+		value, err := c.Flag(key)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if pathFlags[key] {
+			value = c.Local(value)
+		}
+		if err := f.Value.Set(value); err != nil {
+			fmt.Printf("%s: %s: %v\n", c.Path, key, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// knownFlag reports whether a command of root has the flag
+func knownFlag(root *cobra.Command, name string) bool {
+	for _, cmd := range root.Commands() {
+		if cmd.Flags().Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func doctorCmd() *cobra.Command {
+	var format string
+
+	var cmdDoctor = &cobra.Command{
+		Use:   "doctor [files or directories]",
+		Short: "Report the constructs tojen can not convert faithfully",
+		Long:  `Convert every declaration, statement and expression of the Go files on its own and report those that make the conversion fail or that the generator drops or approximates, with a summary by node kind. Directories ending in /... are walked, ./... by default. With --format sarif a SARIF log is printed instead. The exit status is 1 when there are findings.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				args = []string{"./..."}
+			}
+			if format != "text" && format != "sarif" {
+				fmt.Println("unknown format " + format + ", want text or sarif")
+				os.Exit(1)
+			}
+			paths, err := gen.GoFiles(args)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			findings, err := gen.Doctor(paths)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if format == "sarif" {
+				b, err := gen.SARIF(findings)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Println(string(b))
+			} else {
+				printFindings(findings, len(paths))
+			}
+			if len(findings) > 0 {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		},
+	}
+	cmdDoctor.Flags().StringVar(&format, "format", "text", "Output format, text or sarif")
+	return cmdDoctor
+}
+
+// printFindings prints the findings and a table of them by node kind
+func printFindings(findings []gen.Finding, files int) {
+	type count struct{ unsupported, approximated int }
+	counts := map[string]*count{}
+	withFindings := map[string]bool{}
+	for _, f := range findings {
+		fmt.Println(f)
+		c := counts[f.Node]
+		if c == nil {
+			c = &count{}
+			counts[f.Node] = c
+		}
+		if f.Unsupported {
+			c.unsupported++
+		} else {
+			c.approximated++
+		}
+		withFindings[f.File] = true
+	}
+	if len(findings) > 0 {
+		var nodes []string
+		for node := range counts {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tUNSUPPORTED\tAPPROXIMATED")
+		for _, node := range nodes {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", node, counts[node].unsupported, counts[node].approximated)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+	fmt.Printf("%d files, %d safe to convert\n", files, files-len(withFindings))
+}
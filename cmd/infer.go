@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func inferCmd() *cobra.Command {
+	var packageName string
+	var output string
+	var genMain bool
+	var formating bool
+
+	var cmdInfer = &cobra.Command{
+		Use:   "infer [example files...]",
+		Short: "Infer a parameterized generator from several example files",
+		Long:  `Infer a single generator from several structurally similar .go files. Parts that differ between the examples become fields of a Params struct, runs of similar nodes become loops and nodes found only in some examples become conditionals. If output path is set then it will write the generated code to the output path, otherwise it will print it out to the console.`,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var srcs [][]byte
+			for _, arg := range args {
+				b, err := ioutil.ReadFile(arg)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				srcs = append(srcs, b)
+			}
+			if packageName == "" {
+				packageName = defaultPackage()
+			}
+			report := &gen.Report{}
+			retBytes, err := gen.InferFileBytesWith(srcs, packageName, genMain, formating, gen.Options{Report: report})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			checkWarnings(report)
+			if output != "" {
+				writeOutput(retBytes, output)
+			}
+			fmt.Println(string(retBytes))
+			os.Exit(0)
+		},
+	}
+	cmdInfer.Flags().StringVarP(&packageName, "package", "p", "", "Name of package")
+	cmdInfer.Flags().StringVarP(&output, "output", "o", "", "Path to write the generated code to")
+	cmdInfer.Flags().BoolVarP(&genMain, "main", "m", false, "Generate main function that prints out the code of every example when called -- used for testing.")
+	cmdInfer.Flags().BoolVarP(&formating, "formatted", "f", false, "Format the generated code EXPERIMENTAL")
+	cmdInfer.Flags().BoolVar(&strict, "strict", false, "Fail when the conversion has warnings, like dropped struct tags")
+	return cmdInfer
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func initCmd() *cobra.Command {
+	var opts gen.Options
+	var output string
+
+	var cmdInit = &cobra.Command{
+		Use:   "init [source file] [directory]",
+		Short: "Create a generator module for a file",
+		Long:  `Create a module in the directory with the generator of the source file, a go.mod requiring jennifer and a main function writing the generated code to the path given as its argument. The //go:generate directive running it from the package of the source is printed, it writes to name_gen.go next to the source name.go unless --output is given.`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			applyConfig(cmd, args[0])
+			if err := opts.Validate(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			directive, err := gen.InitProject(args[0], args[1], output, opts)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Println("Successfuly created the generator in " + args[1])
+			fmt.Println("Put this line in " + args[0] + " to run it with go generate:")
+			fmt.Println(directive)
+			os.Exit(0)
+		},
+	}
+	cmdInit.Flags().StringVarP(&output, "output", "o", "", "Path the directive writes the generated code to, name_gen.go next to the source name.go by default")
+	cmdInit.Flags().StringSliceVar(&opts.Fields, "fields", nil, "Struct types whose generators take the type name and a slice of fields")
+	cmdInit.Flags().StringSliceVar(&opts.Interfaces, "interfaces", nil, "Interfaces whose implementations are generated from a slice of methods")
+	cmdInit.Flags().StringSliceVar(&opts.Enums, "enums", nil, "Enum types whose const blocks and member lists are generated from a slice of members")
+	cmdInit.Flags().StringSliceVar(&opts.Only, "only", nil, "Names of the declarations to generate, methods as Name or Type.Name")
+	cmdInit.Flags().StringSliceVar(&opts.Kinds, "kind", nil, "Kinds of the declarations to generate: const, var, type and func")
+	cmdInit.Flags().StringSliceVar(&opts.Exclude, "exclude", nil, "Names of the declarations to leave out")
+	cmdInit.Flags().StringVar(&opts.Naming, "naming", "", "How generator functions of declarations are named: position, like genDeclAt29, or name, like genTypeUser")
+	cmdInit.Flags().BoolVar(&opts.Lenient, "lenient", false, "Keep the source of constructs tojen can not convert verbatim, with a warning, instead of failing")
+	cmdInit.Flags().BoolVar(&opts.Partial, "partial", false, "Convert the declarations of files with syntax errors that parse, leaving out the others with a warning")
+	cmdInit.Flags().BoolVar(&opts.Factor, "factor", false, "Generate runs of similar statements, fields and elements with a loop over their differences")
+	return cmdInit
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func renderCmd() *cobra.Command {
+	var output string
+	var outDir string
+
+	var cmdRender = &cobra.Command{
+		Use:   "render [generator.go] [-- args...]",
+		Short: "Print the code a generator produces",
+		Long:  `Build and run a jennifer generator, made by tojen or by hand, in a temporary module and print or write the code it produces. The arguments after -- are passed to the generator. A generator without a main function gets one printing what genPackage or genFile returns, like --main generates. The txtar archive printed by the generator of a package can be written as files with --out-dir.`,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if n := cmd.ArgsLenAtDash(); n > 1 || (n == -1 && len(args) > 1) {
+				fmt.Println("the arguments of the generator go after --")
+				os.Exit(1)
+			}
+			generator, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			out, err := gen.Render(generator, args[1:]...)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if outDir != "" {
+				files := map[string][]byte{}
+				for _, f := range gen.ParseTxtar(out).Files {
+					files[f.Name] = f.Data
+				}
+				if len(files) == 0 {
+					fmt.Println("the generator did not print a txtar archive")
+					os.Exit(1)
+				}
+				err = os.MkdirAll(outDir, 0755)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				for name, b := range files {
+					writeFile(filepath.Join(outDir, name), b)
+				}
+				fmt.Println("Successfuly wrote " + strconv.Itoa(len(files)) + " files to " + outDir)
+				os.Exit(0)
+			}
+			if output != "" {
+				writeOutput(out, output)
+			}
+			fmt.Print(string(out))
+			os.Exit(0)
+		},
+	}
+	cmdRender.Flags().StringVarP(&output, "output", "o", "", "Path to write the produced code to")
+	cmdRender.Flags().StringVar(&outDir, "out-dir", "", "Write the files of the txtar archive the generator prints to this directory")
+	return cmdRender
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aloder/tojen/gen"
+)
+
+// genReport is the report of the conversion of gen, holding its warnings
+var genReport *gen.Report
+
+// jsonReport is set by --report json, strict by --strict
+var jsonReport, strict bool
+
+// checkWarnings prints the warnings of the report of a conversion and exits
+// when there are any with --strict
+func checkWarnings(r *gen.Report) {
+	warnings := r.Warnings()
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "warning: "+w)
+	}
+	if strict && len(warnings) > 0 {
+		fmt.Println(strconv.Itoa(len(warnings)) + " warnings in strict mode")
+		os.Exit(1)
+	}
+}
+
+// succeed prints the message, or the report with the output path when there
+// is one, and exits
+func succeed(message, output string) {
+	if !jsonReport {
+		fmt.Println(message)
+		os.Exit(0)
+	}
+	genReport.Output = output
+	b, err := json.MarshalIndent(genReport, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println(string(b))
+	os.Exit(0)
+}
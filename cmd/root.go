@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/aloder/tojen/gen"
 	"github.com/spf13/cobra"
@@ -13,44 +16,104 @@ func Execute() {
 	var packageName string
 	var genMain bool
 	var formating bool
+	var fields []string
+	var interfaces []string
+	var enums []string
+	var factor bool
+	var only []string
+	var kinds []string
+	var exclude []string
+	var naming string
+	var jenPath string
+	var reportFormat string
+	var lenient bool
+	var partial bool
+	var tags []string
+	var outDir string
+	var output string
+	var into string
+	var lines string
+	var at string
 
 	var cmdGen = &cobra.Command{
-		Use:   "gen [path to file] [output path]",
+		Use:   "gen [path to file or package] [output path]",
 		Short: "Generate code from file",
-		Long:  `Generate code from a .go file. If output path is set then it will write the generated code to the output path, otherwise it will print it out to the console.`,
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Generate code from a .go file. If output path is set then it will write the generated code to the output path, otherwise it will print it out to the console.
+
+Run by go generate the source defaults to $GOFILE and the package to $GOPACKAGE, so that //go:generate tojen gen -o gen.go converts the file holding the directive.
+
+Given a directory or an import path every non-test file of the package is converted, with a generator for each file and genPackage returning all of them. A .txtar archive is converted like a package of its Go files.`,
+		Args: cobra.RangeArgs(goGenerateArgs(), 2),
 		Run: func(cmd *cobra.Command, args []string) {
-			b, err := ioutil.ReadFile(args[0])
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+			if len(args) == 0 {
+				args = []string{os.Getenv("GOFILE")}
+			}
+			applyConfig(cmd, args[0])
+			if output != "" {
+				args = append(args[:1], output)
 			}
 			if packageName == "" {
-				packageName = "main"
+				packageName = defaultPackage()
 			}
-			retBytes, err := gen.GenerateFileBytes(b, packageName, genMain, formating)
-			if err != nil {
+			opts := gen.Options{Fields: fields, Interfaces: interfaces, Enums: enums, Factor: factor, Only: only, Kinds: kinds, Exclude: exclude, Naming: naming, JenPath: jenPath, Lenient: lenient, Partial: partial}
+			if err := opts.Validate(); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
-			if len(args) == 2 {
-				osFile, err := os.Create(args[1])
+			if reportFormat != "" {
+				if reportFormat != "json" {
+					fmt.Println("unknown report format " + reportFormat + ", want json")
+					os.Exit(1)
+				}
+				if len(args) < 2 && outDir == "" && into == "" {
+					fmt.Println("--report needs an output path, --out-dir or --into")
+					os.Exit(1)
+				}
+				jsonReport = true
+			}
+			genReport = &gen.Report{}
+			opts.Report = genReport
+			if lines != "" {
+				genLines(args[0], lines, opts)
+			}
+			if into != "" {
+				genInto(args[0], into, at, formating, opts)
+			}
+			if strings.HasSuffix(args[0], ".txtar") {
+				b, err := ioutil.ReadFile(args[0])
 				if err != nil {
 					fmt.Println(err)
 					os.Exit(1)
 				}
-				_, err = osFile.Write(retBytes)
+				files := gen.ParseTxtar(b).GoFiles()
+				genPackage(files, args, packageName, genMain, formating, opts, outDir)
+			}
+			if info, err := os.Stat(args[0]); err != nil || info.IsDir() {
+				dir, err := gen.PackageDir(args[0])
 				if err != nil {
 					fmt.Println(err)
 					os.Exit(1)
 				}
-				fmt.Println("Successfuly wrote file to " + args[1])
-				err = osFile.Close()
+				files, err := gen.PackageFiles(dir, tags)
 				if err != nil {
 					fmt.Println(err)
 					os.Exit(1)
 				}
-				os.Exit(0)
+				genPackage(files, args, packageName, genMain, formating, opts, outDir)
+			}
+			b, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			retBytes, err := gen.GenerateFileBytesWith(b, packageName, genMain, formating, opts)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			checkWarnings(genReport)
+			if len(args) == 2 {
+				writeOutput(retBytes, args[1])
 			}
 			fmt.Println(string(retBytes))
 			os.Exit(0)
@@ -63,11 +126,158 @@ func Execute() {
 		Long:  `Generate jennifer code from a file with the command gen`,
 	}
 	cmdGen.Flags().StringVarP(&packageName, "package", "p", "", "Name of package")
+	cmdGen.Flags().StringVarP(&output, "output", "o", "", "Path to write the generated code to, instead of the output path argument")
 	cmdGen.Flags().BoolVarP(&genMain, "main", "m", false, "Generate main function that prints out the generated code when called -- used for testing.")
 
 	cmdGen.Flags().BoolVarP(&formating, "formatted", "f", false, "Format the generated code EXPERIMENTAL")
+	cmdGen.Flags().StringSliceVar(&fields, "fields", nil, "Struct types whose generators take the type name and a slice of fields")
+	cmdGen.Flags().StringSliceVar(&interfaces, "interfaces", nil, "Interfaces whose implementations are generated from a slice of methods")
+	cmdGen.Flags().StringSliceVar(&enums, "enums", nil, "Enum types whose const blocks and member lists are generated from a slice of members")
+	cmdGen.Flags().StringSliceVar(&only, "only", nil, "Names of the declarations to generate, methods as Name or Type.Name")
+	cmdGen.Flags().StringSliceVar(&kinds, "kind", nil, "Kinds of the declarations to generate: const, var, type and func")
+	cmdGen.Flags().StringSliceVar(&exclude, "exclude", nil, "Names of the declarations to leave out")
+	cmdGen.Flags().StringVar(&naming, "naming", "", "How generator functions of declarations are named: position, like genDeclAt29, or name, like genTypeUser")
+	cmdGen.Flags().StringVar(&jenPath, "jen", "", "Import path of jennifer in the generated code, for a fork or a vendored copy")
+	cmdGen.Flags().StringSliceVar(&tags, "tags", nil, "Build tags selecting the files of a package")
+	cmdGen.Flags().StringVar(&outDir, "out-dir", "", "Write the generator of every file of a package to its own file in this directory")
+	cmdGen.Flags().BoolVar(&strict, "strict", false, "Fail when the conversion has warnings, like dropped struct tags")
+	cmdGen.Flags().StringVar(&reportFormat, "report", "", "Print a report of the conversion in this format, json, instead of the success message")
+	cmdGen.Flags().StringVar(&lines, "lines", "", "Print only the jennifer code of the declarations or statements on the lines from:to")
+	cmdGen.Flags().StringVar(&into, "into", "", "Insert the generator functions into this existing generator instead of writing a new file")
+	cmdGen.Flags().StringVar(&at, "at", "tojen:insert", "Text of the comment in the --into generator to insert the functions at")
+	cmdGen.Flags().BoolVar(&lenient, "lenient", false, "Keep the source of constructs tojen can not convert verbatim, with a warning, instead of failing")
+	cmdGen.Flags().BoolVar(&partial, "partial", false, "Convert the declarations of files with syntax errors that parse, leaving out the others with a warning")
+	cmdGen.Flags().BoolVar(&factor, "factor", false, "Generate runs of similar statements, fields and elements with a loop over their differences")
 
-	rootCmd.AddCommand(cmdGen)
+	rootCmd.AddCommand(cmdGen, inferCmd(), batchCmd(), watchCmd(), verifyCmd(), checkCmd(), updateCmd(), renderCmd(), initCmd(), exprCmd(), stmtCmd(), doctorCmd())
 	rootCmd.Execute()
 
 }
+
+// goGenerateArgs returns the least number of arguments of gen, none when run
+// by go generate since the source defaults to $GOFILE
+func goGenerateArgs() int {
+	if os.Getenv("GOFILE") != "" {
+		return 0
+	}
+	return 1
+}
+
+// defaultPackage returns the name of the package of generated code, the
+// package running go generate or main
+func defaultPackage() string {
+	if p := os.Getenv("GOPACKAGE"); p != "" {
+		return p
+	}
+	return "main"
+}
+
+// genLines prints the jennifer code of the lines of the file at src and exits
+func genLines(src, lines string, opts gen.Options) {
+	from, to, err := parseLines(lines)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	codes, _, err := gen.GenerateLines(b, from, to, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	checkWarnings(genReport)
+	fmt.Println(gen.SnippetCode(codes...))
+	os.Exit(0)
+}
+
+// genInto inserts the generator of the file at src into the generator at
+// into and exits
+func genInto(src, into, at string, formating bool, opts gen.Options) {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	target, err := ioutil.ReadFile(into)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	retBytes, err := gen.GenerateInto(target, at, b, formating, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	checkWarnings(genReport)
+	writeOutput(retBytes, into)
+}
+
+// genPackage converts the files of a package and exits. An output path
+// ending in .txtar gets the generator of every file in a txtar archive.
+func genPackage(files map[string][]byte, args []string, packageName string, genMain, formating bool, opts gen.Options, outDir string) {
+	if len(args) == 2 && strings.HasSuffix(args[1], ".txtar") {
+		out, err := gen.GeneratePackageFiles(files, packageName, genMain, formating, opts)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		checkWarnings(genReport)
+		writeOutput(gen.TxtarOf(out).Format(), args[1])
+	}
+	if outDir != "" {
+		out, err := gen.GeneratePackageFiles(files, packageName, genMain, formating, opts)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		checkWarnings(genReport)
+		err = os.MkdirAll(outDir, 0755)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for name, b := range out {
+			err = ioutil.WriteFile(filepath.Join(outDir, name), b, 0644)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		succeed("Successfuly wrote "+strconv.Itoa(len(out))+" files to "+outDir, outDir)
+	}
+	retBytes, err := gen.GeneratePackageBytes(files, packageName, genMain, formating, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	checkWarnings(genReport)
+	if len(args) == 2 {
+		writeOutput(retBytes, args[1])
+	}
+	fmt.Println(string(retBytes))
+	os.Exit(0)
+}
+
+// writeOutput writes the generated code to path and exits
+func writeOutput(b []byte, path string) {
+	osFile, err := os.Create(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	_, err = osFile.Write(b)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	err = osFile.Close()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	succeed("Successfuly wrote file to "+path, path)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func exprCmd() *cobra.Command {
+	var lenient bool
+	var cmdExpr = &cobra.Command{
+		Use:   "expr [expression]",
+		Short: "Print the jennifer code of an expression",
+		Long:  `Print the jennifer code of a Go expression, to paste into a generator. Selectors on packages are generated with Id since a snippet has no imports.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			report := &gen.Report{}
+			code, _, err := gen.GenerateExpr(args[0], gen.Options{Lenient: lenient, Report: report})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			checkWarnings(report)
+			fmt.Println(gen.SnippetCode(code))
+			os.Exit(0)
+		},
+	}
+	cmdExpr.Flags().BoolVar(&lenient, "lenient", false, "Keep the source of constructs tojen can not convert verbatim, with a warning, instead of failing")
+	cmdExpr.Flags().BoolVar(&strict, "strict", false, "Fail when the conversion has warnings, like dropped literals")
+	return cmdExpr
+}
+
+func stmtCmd() *cobra.Command {
+	var lenient bool
+	var cmdStmt = &cobra.Command{
+		Use:   "stmt [statements]",
+		Short: "Print the jennifer code of statements",
+		Long:  `Print the jennifer code of every statement of a list of Go statements, to paste into a generator. Selectors on packages are generated with Id since a snippet has no imports.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			report := &gen.Report{}
+			codes, _, err := gen.GenerateStmts(args[0], gen.Options{Lenient: lenient, Report: report})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			checkWarnings(report)
+			fmt.Println(gen.SnippetCode(codes...))
+			os.Exit(0)
+		},
+	}
+	cmdStmt.Flags().BoolVar(&lenient, "lenient", false, "Keep the source of constructs tojen can not convert verbatim, with a warning, instead of failing")
+	cmdStmt.Flags().BoolVar(&strict, "strict", false, "Fail when the conversion has warnings, like dropped literals")
+	return cmdStmt
+}
+
+// parseLines parses a line range like 40:72
+func parseLines(lines string) (int, int, error) {
+	var from, to int
+	_, err := fmt.Sscanf(strings.Replace(lines, ":", " ", 1), "%d %d", &from, &to)
+	if err != nil || from < 1 || to < from {
+		return 0, 0, fmt.Errorf("invalid line range %q, want from:to", lines)
+	}
+	return from, to, nil
+}
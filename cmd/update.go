@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func updateCmd() *cobra.Command {
+	var e gen.BatchEntry
+	var basePath string
+
+	var cmdUpdate = &cobra.Command{
+		Use:   "update [path to file or package] [generator.go]",
+		Short: "Regenerate a generator keeping its hand edits",
+		Long: `Regenerate a generator from its source and merge the changes into the generator, keeping the edits made by hand. The generator tojen generated last is kept next to the generator with the suffix .base, and every function is merged on its own: functions without edits are replaced, edited functions are kept while the declaration they generate is the same and merged line by line otherwise. Conflicts are marked like git does and the exit status is 1.
+
+Without a generator at the path the generator and its base are written.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			applyConfig(cmd, args[0])
+			e.Input, e.Output = args[0], args[1]
+			if basePath == "" {
+				basePath = e.Output + ".base"
+			}
+			files, err := e.Read()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			theirs, err := e.Generate(files)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			ours, err := ioutil.ReadFile(e.Output)
+			if os.IsNotExist(err) {
+				writeFile(basePath, theirs)
+				writeOutput(theirs, e.Output)
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			base, err := ioutil.ReadFile(basePath)
+			if err != nil {
+				fmt.Println(err)
+				fmt.Println("the base is the generator as tojen generated it, before it was edited")
+				os.Exit(1)
+			}
+			m, err := gen.Update(base, ours, theirs)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			writeFile(e.Output, m.Code)
+			writeFile(basePath, theirs)
+			for _, l := range []struct {
+				what  string
+				names []string
+			}{{"updated", m.Updated}, {"kept", m.Kept}, {"merged", m.Merged}, {"conflicts in", m.Conflicts}} {
+				if len(l.names) > 0 {
+					fmt.Println(l.what + " " + strings.Join(l.names, ", "))
+				}
+			}
+			if len(m.Conflicts) > 0 {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		},
+	}
+	cmdUpdate.Flags().StringVar(&basePath, "base", "", "Path of the generator as tojen last generated it, the generator path with .base added by default")
+	cmdUpdate.Flags().StringVarP(&e.Package, "package", "p", defaultPackage(), "Name of package")
+	cmdUpdate.Flags().BoolVarP(&e.Main, "main", "m", false, "Generate main function that prints out the generated code when called -- used for testing.")
+	cmdUpdate.Flags().BoolVarP(&e.Formatted, "formatted", "f", false, "Format the generated code EXPERIMENTAL")
+	cmdUpdate.Flags().StringSliceVar(&e.Fields, "fields", nil, "Struct types whose generators take the type name and a slice of fields")
+	cmdUpdate.Flags().StringSliceVar(&e.Interfaces, "interfaces", nil, "Interfaces whose implementations are generated from a slice of methods")
+	cmdUpdate.Flags().StringSliceVar(&e.Enums, "enums", nil, "Enum types whose const blocks and member lists are generated from a slice of members")
+	cmdUpdate.Flags().StringSliceVar(&e.Only, "only", nil, "Names of the declarations to generate, methods as Name or Type.Name")
+	cmdUpdate.Flags().StringSliceVar(&e.Kinds, "kind", nil, "Kinds of the declarations to generate: const, var, type and func")
+	cmdUpdate.Flags().StringSliceVar(&e.Exclude, "exclude", nil, "Names of the declarations to leave out")
+	cmdUpdate.Flags().StringVar(&e.Naming, "naming", "", "How generator functions of declarations are named: position, like genDeclAt29, or name, like genTypeUser")
+	cmdUpdate.Flags().StringVar(&e.JenPath, "jen", "", "Import path of jennifer in the generated code, for a fork or a vendored copy")
+	cmdUpdate.Flags().StringSliceVar(&e.Tags, "tags", nil, "Build tags selecting the files of a package")
+	cmdUpdate.Flags().BoolVar(&e.Lenient, "lenient", false, "Keep the source of constructs tojen can not convert verbatim, with a warning, instead of failing")
+	cmdUpdate.Flags().BoolVar(&e.Partial, "partial", false, "Convert the declarations of files with syntax errors that parse, leaving out the others with a warning")
+	cmdUpdate.Flags().BoolVar(&e.Strict, "strict", false, "Fail when the conversion has warnings, like dropped struct tags")
+	cmdUpdate.Flags().BoolVar(&e.Factor, "factor", false, "Generate runs of similar statements, fields and elements with a loop over their differences")
+	return cmdUpdate
+}
+
+// writeFile writes b to path or exits
+func writeFile(path string, b []byte) {
+	err := ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func verifyCmd() *cobra.Command {
+	var minimize bool
+	var tags []string
+	var cmdVerify = &cobra.Command{
+		Use:   "verify [path to file or package...]",
+		Short: "Check that the generators of files render them back",
+		Long:  `Convert every file, build and run its generator in a temporary module and compare the rendered code to the file. A package or a .txtar archive has each of its files verified. Comments, formatting and the spelling of literals are ignored. The first node that differs is printed with its position in the file and in the rendered code. With --minimize the smallest file made from a failing file that still fails the same way is printed as well.`,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			applyConfig(cmd, args[0])
+			failed := false
+			for _, arg := range args {
+				files, err := gen.BatchEntry{Input: arg, Tags: tags}.Read()
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				var names []string
+				for name := range files {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					path := gen.SourcePath(arg, name)
+					err = gen.Verify(path, files[name])
+					if err != nil {
+						fmt.Println(err)
+						failed = true
+						if minimize {
+							printMinimized(path, files[name])
+						}
+						continue
+					}
+					fmt.Println("ok " + path)
+				}
+			}
+			if failed {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		},
+	}
+	cmdVerify.Flags().StringSliceVar(&tags, "tags", nil, "Build tags selecting the files of a package")
+	cmdVerify.Flags().BoolVar(&minimize, "minimize", false, "remove declarations, statements and expressions of a failing file while it still fails and print what is left")
+	return cmdVerify
+}
+
+// printMinimized prints the smallest file made from src that fails to verify
+// like it and the failure
+func printMinimized(name string, src []byte) {
+	m, err := gen.MinimizeVerify(name, src)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("smallest file that fails:\n%s%v\n", m, gen.Verify(name, m))
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aloder/tojen/gen"
+	"github.com/spf13/cobra"
+)
+
+func watchCmd() *cobra.Command {
+	var e gen.BatchEntry
+	var interval time.Duration
+
+	var cmdWatch = &cobra.Command{
+		Use:   "watch [path to file or package] [output path]",
+		Short: "Regenerate code whenever the source changes",
+		Long:  `Convert a .go file, a .txtar archive or a package like gen does and convert it again whenever its files change, until interrupted. The generator of a package is written to a file for every source file when the output path is a directory. Conversion errors are printed without stopping the watch.`,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			applyConfig(cmd, args[0])
+			e.Input, e.Output = args[0], args[1]
+			fmt.Println("Watching " + e.Input)
+			gen.Watch(e, interval, nil, func(err error) {
+				now := time.Now().Format("15:04:05")
+				if err != nil {
+					fmt.Println(now, err)
+					return
+				}
+				fmt.Println(now, "Successfuly wrote file to "+e.Output)
+			})
+		},
+	}
+	cmdWatch.Flags().StringVarP(&e.Package, "package", "p", defaultPackage(), "Name of package")
+	cmdWatch.Flags().BoolVarP(&e.Main, "main", "m", false, "Generate main function that prints out the generated code when called -- used for testing.")
+	cmdWatch.Flags().BoolVarP(&e.Formatted, "formatted", "f", false, "Format the generated code EXPERIMENTAL")
+	cmdWatch.Flags().StringSliceVar(&e.Fields, "fields", nil, "Struct types whose generators take the type name and a slice of fields")
+	cmdWatch.Flags().StringSliceVar(&e.Interfaces, "interfaces", nil, "Interfaces whose implementations are generated from a slice of methods")
+	cmdWatch.Flags().StringSliceVar(&e.Enums, "enums", nil, "Enum types whose const blocks and member lists are generated from a slice of members")
+	cmdWatch.Flags().StringSliceVar(&e.Only, "only", nil, "Names of the declarations to generate, methods as Name or Type.Name")
+	cmdWatch.Flags().StringSliceVar(&e.Kinds, "kind", nil, "Kinds of the declarations to generate: const, var, type and func")
+	cmdWatch.Flags().StringSliceVar(&e.Exclude, "exclude", nil, "Names of the declarations to leave out")
+	cmdWatch.Flags().StringVar(&e.Naming, "naming", "", "How generator functions of declarations are named: position, like genDeclAt29, or name, like genTypeUser")
+	cmdWatch.Flags().StringVar(&e.JenPath, "jen", "", "Import path of jennifer in the generated code, for a fork or a vendored copy")
+	cmdWatch.Flags().StringSliceVar(&e.Tags, "tags", nil, "Build tags selecting the files of a package")
+	cmdWatch.Flags().BoolVar(&e.Lenient, "lenient", false, "Keep the source of constructs tojen can not convert verbatim, with a warning, instead of failing")
+	cmdWatch.Flags().BoolVar(&e.Partial, "partial", false, "Convert the declarations of files with syntax errors that parse, leaving out the others with a warning")
+	cmdWatch.Flags().BoolVar(&e.Strict, "strict", false, "Fail when the conversion has warnings, like dropped struct tags")
+	cmdWatch.Flags().BoolVar(&e.Factor, "factor", false, "Generate runs of similar statements, fields and elements with a loop over their differences")
+	cmdWatch.Flags().DurationVar(&interval, "interval", 500*time.Millisecond, "How often to poll the source for changes")
+	return cmdWatch
+}
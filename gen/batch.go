@@ -0,0 +1,373 @@
+package gen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Version is the version of the tojen module the running program is built
+// with, followed by the commit for a build of a checkout
+var Version = buildVersion()
+
+const modulePath = "github.com/aloder/tojen"
+
+// buildVersion returns the version of tojen in the build information
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path != modulePath {
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				return dep.Version
+			}
+		}
+		return "(devel)"
+	}
+	v := info.Main.Version
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision":
+			v += " " + s.Value
+		case s.Key == "vcs.modified" && s.Value == "true":
+			v += " modified"
+		}
+	}
+	return v
+}
+
+var (
+	cacheVersionOnce sync.Once
+	cacheVersion     string
+)
+
+// versionKey returns the version of tojen in the cache key of batch
+// entries, so that a new build converts everything again. A build without a
+// released version or with local changes is told apart by the hash of the
+// executable.
+func versionKey() string {
+	cacheVersionOnce.Do(func() {
+		cacheVersion = Version
+		if !strings.HasPrefix(Version, "(devel)") && !strings.HasSuffix(Version, " modified") {
+			return
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return
+		}
+		b, err := ioutil.ReadFile(exe)
+		if err != nil {
+			return
+		}
+		sum := sha256.Sum256(b)
+		cacheVersion += " " + hex.EncodeToString(sum[:])
+	})
+	return cacheVersion
+}
+
+// Manifest lists the conversions of a batch
+type Manifest struct {
+	Entries []BatchEntry `json:"entries"`
+}
+
+// BatchEntry is one conversion of a batch. Input is a .go file, a .txtar
+// archive, a package directory or an import path, converted like tojen gen
+// does. Relative paths are relative to the manifest.
+type BatchEntry struct {
+	Input      string   `json:"input"`
+	Output     string   `json:"output"`
+	Package    string   `json:"package,omitempty"`
+	Main       bool     `json:"main,omitempty"`
+	Formatted  bool     `json:"formatted,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
+	Interfaces []string `json:"interfaces,omitempty"`
+	Enums      []string `json:"enums,omitempty"`
+	Factor     bool     `json:"factor,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Only       []string `json:"only,omitempty"`
+	Kinds      []string `json:"kinds,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+	Naming     string   `json:"naming,omitempty"`
+	JenPath    string   `json:"jen,omitempty"`
+	Lenient    bool     `json:"lenient,omitempty"`
+	Partial    bool     `json:"partial,omitempty"`
+	Strict     bool     `json:"strict,omitempty"`
+}
+
+// BatchResult is the outcome of a batch entry
+type BatchResult struct {
+	Entry BatchEntry
+	// Skipped is set when the input, options and version are the same as in
+	// the last run and the output is still there
+	Skipped bool
+	Err     error
+}
+
+// ReadManifest reads a manifest from path, making the paths of its entries
+// relative to the working directory
+func ReadManifest(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		if e.Input == "" || e.Output == "" {
+			return nil, fmt.Errorf("%s: entry %d needs an input and an output", path, i+1)
+		}
+		if local(dir, e.Input) {
+			e.Input = filepath.Join(dir, e.Input)
+		}
+		if !filepath.IsAbs(e.Output) {
+			e.Output = filepath.Join(dir, e.Output)
+		}
+	}
+	return m, nil
+}
+
+// local reports whether a relative input is a path in dir rather than an
+// import path
+func local(dir, input string) bool {
+	if filepath.IsAbs(input) {
+		return false
+	}
+	if strings.HasPrefix(input, ".") {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(dir, input))
+	return err == nil
+}
+
+// BatchCache maps the outputs of a batch to the hash of the entry that last
+// wrote them
+type BatchCache map[string]string
+
+// ReadBatchCache reads the cache at path. A missing cache is empty.
+func ReadBatchCache(path string) (BatchCache, error) {
+	c := BatchCache{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Write writes the cache to path
+func (c BatchCache) Write(path string) error {
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// RunBatch converts the entries of the manifest with workers conversions at a
+// time. Entries whose hash is in the cache are skipped, and the cache is
+// updated with the entries converted without error. The results are in the
+// order of the entries.
+func RunBatch(m *Manifest, cache BatchCache, workers int) []BatchResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]BatchResult, len(m.Entries))
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				e := m.Entries[i]
+				results[i] = BatchResult{Entry: e}
+				files, err := e.Read()
+				if err != nil {
+					results[i].Err = err
+					continue
+				}
+				hash := entryHash(e, files)
+				mu.Lock()
+				cached := cache[e.Output] == hash
+				mu.Unlock()
+				if _, err := os.Stat(e.Output); cached && err == nil {
+					results[i].Skipped = true
+					continue
+				}
+				if err := e.Convert(files); err != nil {
+					results[i].Err = fmt.Errorf("%s: %v", e.Input, err)
+					continue
+				}
+				mu.Lock()
+				cache[e.Output] = hash
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range m.Entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// Read reads the Go files of the input of the entry. A single .go file is
+// keyed by an empty name.
+func (e BatchEntry) Read() (map[string][]byte, error) {
+	if strings.HasSuffix(e.Input, ".txtar") {
+		b, err := ioutil.ReadFile(e.Input)
+		if err != nil {
+			return nil, err
+		}
+		return ParseTxtar(b).GoFiles(), nil
+	}
+	if info, err := os.Stat(e.Input); err == nil && !info.IsDir() {
+		b, err := ioutil.ReadFile(e.Input)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"": b}, nil
+	}
+	dir, err := PackageDir(e.Input)
+	if err != nil {
+		return nil, err
+	}
+	return PackageFiles(dir, e.Tags)
+}
+
+// entryHash hashes the version, the options of the entry and its files
+func entryHash(e BatchEntry, files map[string][]byte) string {
+	h := sha256.New()
+	opts, _ := json.Marshal(e)
+	fmt.Fprintf(h, "%s\n%s\n", versionKey(), opts)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s %d\n", name, len(files[name]))
+		h.Write(files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Convert converts the files read from the input of the entry and writes
+// the output. The generator of a package goes to a single file when the
+// output ends in .go, to a txtar archive when it ends in .txtar and to a file
+// for every source file in the output directory otherwise.
+func (e BatchEntry) Convert(files map[string][]byte) (err error) {
+	var b []byte
+	if _, ok := files[""]; (ok && len(files) == 1) || strings.HasSuffix(e.Output, ".go") {
+		b, err = e.Generate(files)
+	} else {
+		b, err = e.generateFiles(files)
+	}
+	if err != nil || b == nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Output), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(e.Output, b, 0644)
+}
+
+// Generate returns the generator of the files read from the input of the
+// entry as a single file
+func (e BatchEntry) Generate(files map[string][]byte) (b []byte, err error) {
+	// the converter panics on code it does not support
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	opts := e.options()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if src, ok := files[""]; ok && len(files) == 1 {
+		b, err = GenerateFileBytesWith(src, e.packName(), e.Main, e.Formatted, opts)
+	} else {
+		b, err = GeneratePackageBytes(files, e.packName(), e.Main, e.Formatted, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, strictError(opts.Report)
+}
+
+// generateFiles returns the generators of a package as a txtar archive or,
+// when the output is a directory, writes them and returns nil
+func (e BatchEntry) generateFiles(files map[string][]byte) (b []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	opts := e.options()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	out, err := GeneratePackageFiles(files, e.packName(), e.Main, e.Formatted, opts)
+	if err == nil {
+		err = strictError(opts.Report)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(e.Output, ".txtar") {
+		return nil, writeFiles(e.Output, out)
+	}
+	return TxtarOf(out).Format(), nil
+}
+
+func (e BatchEntry) packName() string {
+	if e.Package == "" {
+		return "main"
+	}
+	return e.Package
+}
+
+// options returns the options of the entry, with a report collecting the
+// warnings when it is strict
+func (e BatchEntry) options() Options {
+	var r *Report
+	if e.Strict {
+		r = &Report{}
+	}
+	return Options{Fields: e.Fields, Interfaces: e.Interfaces, Enums: e.Enums, Factor: e.Factor, Only: e.Only, Kinds: e.Kinds, Exclude: e.Exclude, Naming: e.Naming, JenPath: e.JenPath, Lenient: e.Lenient, Partial: e.Partial, Report: r}
+}
+
+// writeFiles writes the files by name to dir
+func writeFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package gen
+
+import (
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aloder/tojen/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	var entries string
+	for i := 0; i < 8; i++ {
+		code := fmt.Sprintf("package main\n\ntype T%d struct {\n\tA int\n}\n\nfunc main() {}\n", i)
+		err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("t%d.go", i)), []byte(code), 0644)
+		if err != nil {
+			assert.Nil(t, err)
+			return
+		}
+		if i > 0 {
+			entries += ","
+		}
+		entries += fmt.Sprintf(`{"input": "t%d.go", "output": "out/t%d.go", "main": true}`, i, i)
+	}
+	manifest := filepath.Join(dir, "tojen.json")
+	err = ioutil.WriteFile(manifest, []byte(`{"entries": [`+entries+`]}`), 0644)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+
+	m, err := ReadManifest(manifest)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	cache := BatchCache{}
+	for _, r := range RunBatch(m, cache, 4) {
+		assert.Nil(t, r.Err)
+		assert.False(t, r.Skipped)
+	}
+	out, err := ioutil.ReadFile(filepath.Join(dir, "out", "t3.go"))
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	ret, err := run.Exec(string(out))
+	if err != nil {
+		assert.Nil(t, err, "Could not execute rendered test file: \n"+string(out))
+		return
+	}
+	want, _ := format.Source([]byte("package main\n\ntype T3 struct {\n\tA int\n}\n\nfunc main() {}\n"))
+	assert.Equal(t, string(want), *ret)
+
+	cachePath := filepath.Join(dir, ".tojen-cache.json")
+	assert.Nil(t, cache.Write(cachePath))
+	cache, err = ReadBatchCache(cachePath)
+	assert.Nil(t, err)
+	for _, r := range RunBatch(m, cache, 4) {
+		assert.True(t, r.Skipped, r.Entry.Input)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "t1.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	assert.Nil(t, err)
+	m.Entries[2].Factor = true
+	os.Remove(filepath.Join(dir, "out", "t4.go"))
+	for i, r := range RunBatch(m, cache, 4) {
+		assert.Nil(t, r.Err)
+		assert.Equal(t, i != 1 && i != 2 && i != 4, r.Skipped, r.Entry.Input)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "bad.go"), []byte("package main\n\nfunc {"), 0644)
+	assert.Nil(t, err)
+	m := &Manifest{Entries: []BatchEntry{
+		{Input: filepath.Join(dir, "bad.go"), Output: filepath.Join(dir, "bad_gen.go")},
+		{Input: filepath.Join(dir, "missing.go"), Output: filepath.Join(dir, "missing_gen.go")},
+	}}
+	cache := BatchCache{}
+	for _, r := range RunBatch(m, cache, 2) {
+		assert.NotNil(t, r.Err, r.Entry.Input)
+	}
+	assert.Empty(t, cache)
+}
+
+func TestVersionKey(t *testing.T) {
+	// a test binary has no released version, its hash tells builds apart
+	assert.True(t, strings.HasPrefix(versionKey(), Version+" "), versionKey())
+	assert.Len(t, strings.TrimPrefix(versionKey(), Version+" "), 64)
+}
@@ -0,0 +1,142 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aloder/tojen/run"
+	"github.com/dave/jennifer/jen"
+)
+
+// Check runs a generator and compares the code it renders to the source it
+// was generated from. files are the Go files of the source by name, a single
+// file keyed by an empty name. The returned unified diff from the source to
+// the rendered code is empty when the generator still reproduces the source,
+// ignoring comments and formatting. Its files are labelled with the path of
+// the source, a file, package or archive, and the path of the generator.
+func Check(genPath string, generator []byte, source string, files map[string][]byte) (string, error) {
+	out, err := Render(generator)
+	if err != nil {
+		return "", err
+	}
+	rendered := map[string][]byte{"": out}
+	if _, ok := files[""]; !ok || len(files) != 1 {
+		rendered = ParseTxtar(out).GoFiles()
+	}
+
+	names := map[string]bool{}
+	for name := range files {
+		names[name] = true
+	}
+	for name := range rendered {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	diff := ""
+	for _, name := range sorted {
+		src, ok := files[name]
+		got, rok := rendered[name]
+		if ok && rok && Compare(name, src, name, got) == nil {
+			continue
+		}
+		to := genPath
+		if name != "" {
+			to += ":" + name
+		}
+		diff += UnifiedDiff(SourcePath(source, name), to, formatted(src), formatted(got))
+	}
+	return diff, nil
+}
+
+// SourcePath returns the path of the file of a source, a file, package or
+// archive, that BatchEntry.Read names name
+func SourcePath(source, name string) string {
+	switch {
+	case name == "":
+		return source
+	case strings.HasSuffix(source, ".txtar"):
+		return source + ":" + name
+	}
+	return filepath.Join(source, name)
+}
+
+// formatted returns the code gofmt formatted without comments when it parses
+func formatted(code []byte) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, 0)
+	if err != nil {
+		return code
+	}
+	b := &bytes.Buffer{}
+	if err := format.Node(b, fset, f); err != nil {
+		return code
+	}
+	return b.Bytes()
+}
+
+// Render builds and runs a generator with the arguments and returns what it
+// prints. A generator without a main function gets one printing what
+// genPackage or genFile returns.
+func Render(generator []byte, args ...string) ([]byte, error) {
+	prog, err := runnable(generator)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run.ExecFiles(prog, args...)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(*out), nil
+}
+
+// runnable returns the files of a main package running the generator. A
+// generator without a main function gets one printing what genPackage or
+// genFile returns.
+func runnable(generator []byte) (map[string]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "generator.go", generator, 0)
+	if err != nil {
+		return nil, err
+	}
+	code := generator
+	if f.Name.Name != "main" {
+		start, end := fset.Position(f.Name.Pos()).Offset, fset.Position(f.Name.End()).Offset
+		code = append(append(append([]byte{}, code[:start]...), "main"...), code[end:]...)
+	}
+	funcs := map[string]*ast.FuncDecl{}
+	for _, d := range f.Decls {
+		if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil {
+			funcs[fn.Name.Name] = fn
+		}
+	}
+	ret := map[string]string{"generator.go": string(code)}
+	if funcs["main"] != nil {
+		return ret, nil
+	}
+	file := jen.NewFile("main")
+	switch {
+	case funcs["genPackage"] != nil:
+		file.Add(genPackageMain())
+	case funcs["genFile"] != nil && funcs["genFile"].Type.Params.NumFields() == 0:
+		file.Add(newConverter().genMainFunc())
+	default:
+		return nil, errors.New("the generator has no main function and takes parameters, generate it with --main")
+	}
+	b := &bytes.Buffer{}
+	if err := file.Render(b); err != nil {
+		return nil, err
+	}
+	ret["main.go"] = b.String()
+	return ret, nil
+}
@@ -0,0 +1,136 @@
+package gen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var diffTests = []struct {
+	Name string
+	A    string
+	B    string
+	Diff string
+}{
+	{
+		Name: "same",
+		A:    "a\nb\n",
+		B:    "a\nb\n",
+	},
+	{
+		Name: "changed",
+		A:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		B:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+		Diff: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+	},
+	{
+		Name: "two hunks",
+		A:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		B:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		Diff: "--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -7,4 +8,3 @@\n 7\n 8\n 9\n-10\n",
+	},
+	{
+		Name: "from empty",
+		A:    "",
+		B:    "a\n",
+		Diff: "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+a\n",
+	},
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tc := range diffTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, test.Diff, UnifiedDiff("a", "b", []byte(test.A), []byte(test.B)))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\n// main says hi\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n"
+	b := &bytes.Buffer{}
+	err := GenerateFile([]byte(src), "tmpl", false).Render(b)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	generator := b.Bytes()
+
+	diff, err := Check("gen.go", generator, "tmpl.go", map[string][]byte{"": []byte(src)})
+	assert.Nil(t, err)
+	assert.Equal(t, "", diff)
+
+	edited := bytes.Replace([]byte(src), []byte(`"hi"`), []byte(`"hello"`), 1)
+	diff, err = Check("gen.go", generator, "tmpl.go", map[string][]byte{"": edited})
+	assert.Nil(t, err)
+	assert.Contains(t, diff, "--- tmpl.go\n+++ gen.go\n")
+	assert.Contains(t, diff, "-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n")
+	assert.NotContains(t, diff, "says hi")
+
+	archive, err := ioutil.ReadFile(filepath.Join("testdata", "bundle.txtar"))
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	files := ParseTxtar(archive).GoFiles()
+	b.Reset()
+	file, err := GeneratePackage(files, "main", false, Options{})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	assert.Nil(t, file.Render(b))
+	diff, err = Check("gen.go", b.Bytes(), "bundle.txtar", files)
+	assert.Nil(t, err)
+	assert.Equal(t, "", diff)
+
+	// a drifted file of a package is labelled with its path
+	files["store.go"] = append(files["store.go"], "\nvar drift int\n"...)
+	diff, err = Check("gen.go", b.Bytes(), "model", files)
+	assert.Nil(t, err)
+	assert.Contains(t, diff, "--- "+filepath.Join("model", "store.go")+"\n+++ gen.go:store.go\n")
+	assert.NotContains(t, diff, "model.go")
+}
+
+func TestRender(t *testing.T) {
+	generator := `package gen
+
+import "github.com/dave/jennifer/jen"
+
+func genFile() *jen.File {
+	ret := jen.NewFile("x")
+	ret.Var().Id("a").Int()
+	return ret
+}
+`
+	out, err := Render([]byte(generator))
+	assert.Nil(t, err)
+	assert.Equal(t, "package x\n\nvar a int\n", string(out))
+
+	generator = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dave/jennifer/jen"
+)
+
+func main() {
+	ret := jen.NewFile("x")
+	for _, arg := range os.Args[1:] {
+		ret.Var().Id(arg).Int()
+	}
+	fmt.Printf("%#v", ret)
+}
+`
+	out, err = Render([]byte(generator), "a", "b")
+	assert.Nil(t, err)
+	assert.Equal(t, "package x\n\nvar a int\nvar b int\n", string(out))
+
+	_, err = Render([]byte("package main\n\nfunc genFile(name string) {}\n"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,98 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigName is the name of the project configuration file
+const ConfigName = ".tojen.json"
+
+// Config holds project defaults for the flags of tojen, keyed by flag name
+type Config struct {
+	// Path is the path of the file the configuration was read from
+	Path   string
+	Values map[string]interface{}
+}
+
+// FindConfig reads the first configuration file found in dir or one of its
+// parents. It returns nil when there is none.
+func FindConfig(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, ConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return ReadConfig(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ReadConfig reads the configuration file at path
+func ReadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{Path: path}
+	if err := json.Unmarshal(b, &c.Values); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Keys returns the keys of the configuration in order
+func (c *Config) Keys() []string {
+	var ret []string
+	for k := range c.Values {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Flag returns the value of key as the argument of a flag, lists joined by
+// commas
+func (c *Config) Flag(key string) (string, error) {
+	switch v := c.Values[key].(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("%s: %s must be a list of strings", c.Path, key)
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("%s: %s has an unsupported value", c.Path, key)
+}
+
+// Local returns path relative to the working directory, for paths in the
+// configuration which are relative to its file
+func (c *Config) Local(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.Path), path)
+}
@@ -0,0 +1,64 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	assert.Nil(t, os.MkdirAll(sub, 0755))
+
+	c, err := FindConfig(sub)
+	assert.Nil(t, err)
+	assert.Nil(t, c)
+
+	config := `{"package": "gen", "formatted": true, "fields": ["User", "Post"], "out-dir": "out"}`
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ConfigName), []byte(config), 0644))
+	c, err = FindConfig(sub)
+	if err != nil || c == nil {
+		assert.Nil(t, err)
+		assert.NotNil(t, c)
+		return
+	}
+	assert.Equal(t, []string{"fields", "formatted", "out-dir", "package"}, c.Keys())
+	for key, want := range map[string]string{"package": "gen", "formatted": "true", "fields": "User,Post"} {
+		got, err := c.Flag(key)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, key)
+	}
+	out, _ := c.Flag("out-dir")
+	assert.Equal(t, filepath.Join(dir, "out"), c.Local(out))
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ConfigName), []byte(`{"fields": [1]}`), 0644))
+	c, err = FindConfig(sub)
+	assert.Nil(t, err)
+	_, err = c.Flag("fields")
+	assert.NotNil(t, err)
+}
+
+func TestNamingAndJenPath(t *testing.T) {
+	src := []byte("package main\n\ntype User struct{}\n\nfunc (u User) Save() {}\n\nvar x = 1\n")
+	b, err := GenerateFileBytesWith(src, "main", false, false, Options{Naming: "name", JenPath: "example.com/jen"})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	code := string(b)
+	assert.Contains(t, code, "import jen \"example.com/jen\"\n")
+	for _, name := range []string{"genTypeUser", "genFuncUserSave", "genVarX"} {
+		assert.True(t, strings.Contains(code, "func "+name+"() jen.Code {"), name+"\n"+code)
+	}
+	assert.NotNil(t, Options{Naming: "random"}.Validate())
+}
@@ -0,0 +1,235 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Finding is a construct of a file tojen can not convert faithfully. An
+// unsupported construct makes the conversion fail, others are dropped or
+// approximated, see Warning.
+type Finding struct {
+	File string `json:"file"`
+	Warning
+	Unsupported bool `json:"unsupported"`
+}
+
+func (f Finding) String() string {
+	level := "warning"
+	if f.Unsupported {
+		level = "error"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", f.File, f.Position.Line, f.Position.Column, level, f.Message, f.Node)
+}
+
+// GoFiles returns the Go files of the patterns, which are files, directories
+// or directories followed by /... for every directory below them. Like the go
+// tool, directories named testdata or vendor or starting with . or _ are left
+// out of the walk.
+func GoFiles(patterns []string) ([]string, error) {
+	var ret []string
+	for _, p := range patterns {
+		if dir := strings.TrimSuffix(p, "/..."); dir != p {
+			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				name := info.Name()
+				if info.IsDir() {
+					if path != dir && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if strings.HasSuffix(name, ".go") {
+					ret = append(ret, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			ret = append(ret, p)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(p, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, matches...)
+	}
+	return ret, nil
+}
+
+// Doctor converts every part of the files on its own and returns the
+// constructs tojen can not convert faithfully, by file name and position.
+// Only the innermost construct making a conversion fail is reported.
+func Doctor(paths []string) ([]Finding, error) {
+	var ret []Finding
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, doctorFile(path, src)...)
+	}
+	return ret, nil
+}
+
+func doctorFile(name string, src []byte) []Finding {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err != nil {
+		return []Finding{{File: name, Warning: Warning{Node: "File", Category: "syntax", Message: err.Error()}, Unsupported: true}}
+	}
+	cv := newConverter()
+	cv.src = src
+	var ret []Finding
+	for _, imp := range f.Imports {
+		if imp.Name != nil && imp.Name.Name == "." {
+			ret = append(ret, cv.finding(name, imp, ". imports are not supported"))
+		}
+	}
+	// the paths without the dot imports
+	func() {
+		defer func() { recover() }()
+		cv.paths, _ = imports(f.Imports)
+	}()
+	cv.doctor(name, f, &ret)
+	for _, w := range cv.warnings {
+		ret = append(ret, Finding{File: name, Warning: w})
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Position.Offset < ret[j].Position.Offset
+	})
+	return ret
+}
+
+// doctor converts the innermost nodes below n that can not be converted,
+// adding them to findings, and reports whether there are any
+func (cv *converter) doctor(name string, n ast.Node, findings *[]Finding) bool {
+	failed := false
+	ast.Inspect(n, func(c ast.Node) bool {
+		if c == n {
+			return true
+		}
+		if c != nil && cv.doctor(name, c, findings) {
+			failed = true
+		}
+		return false
+	})
+	if failed {
+		return true
+	}
+	if msg := cv.tryConvert(n); msg != "" {
+		*findings = append(*findings, cv.finding(name, n, msg))
+		return true
+	}
+	return false
+}
+
+// tryConvert converts a declaration, statement or expression and returns
+// the message of the panic of the converter, if any
+func (cv *converter) tryConvert(n ast.Node) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = panicMessage(r)
+		}
+	}()
+	switch t := n.(type) {
+	case *ast.FuncDecl:
+		cv.funcDecl(t)
+	case *ast.GenDecl:
+		cv.genDecl(t)
+	case ast.Stmt:
+		cv.stmt(t)
+	case ast.Expr:
+		cv.genExpr(t)
+	}
+	return ""
+}
+
+func (cv *converter) finding(name string, n ast.Node, msg string) Finding {
+	node := fmt.Sprintf("%T", n)
+	return Finding{
+		File: name,
+		Warning: Warning{
+			Position: cv.position(n.Pos()),
+			Node:     node[strings.LastIndex(node, ".")+1:],
+			Category: "unsupported",
+			Message:  msg,
+		},
+		Unsupported: true,
+	}
+}
+
+// SARIF returns the findings as a SARIF 2.1.0 log
+func SARIF(findings []Finding) ([]byte, error) {
+	type region struct {
+		StartLine   int `json:"startLine,omitempty"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region region `json:"region"`
+		} `json:"physicalLocation"`
+	}
+	type message struct {
+		Text string `json:"text"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+	results := []result{}
+	for _, f := range findings {
+		r := result{RuleID: "approximated", Level: "warning", Message: message{f.Message + " (" + f.Node + ")"}}
+		if f.Unsupported {
+			r.RuleID, r.Level = "unsupported", "error"
+		}
+		var l location
+		l.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(f.File)
+		l.PhysicalLocation.Region = region{f.Position.Line, f.Position.Column}
+		r.Locations = []location{l}
+		results = append(results, r)
+	}
+	log := map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":    "tojen",
+				"version": Version,
+				"rules": []rule{
+					{"unsupported", message{"The construct makes the conversion fail"}},
+					{"approximated", message{"The construct is dropped or approximated by the generator"}},
+				},
+			}},
+			"results": results,
+		}},
+	}
+	return json.MarshalIndent(log, "", "  ")
+}
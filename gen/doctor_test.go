@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctor(t *testing.T) {
+	src := `package a
+
+type Pair[K comparable, V any] struct {
+	Key K ` + "`json:\"key\"`" + `
+}
+
+func f() {
+	var p Pair[string, int]
+	_ = p
+	if true {
+		c := 1i
+		_ = c
+	}
+}
+
+func g() int { return 1 }
+`
+	var got []string
+	for _, f := range doctorFile("a.go", []byte(src)) {
+		got = append(got, f.String())
+	}
+	assert.Equal(t, []string{
+		"a.go:3:10: warning: type parameters are dropped (FieldList)",
+		"a.go:4:8: warning: struct tag `json:\"key\"` is dropped (BasicLit)",
+		"a.go:8:8: error: Not Handled gen expr: *ast.IndexListExpr (IndexListExpr)",
+		"a.go:11:8: error: Cannot parse Imaginary Numbers (BasicLit)",
+	}, got)
+
+	findings := doctorFile("b.go", []byte("package b\nfunc ("))
+	if assert.Len(t, findings, 1) {
+		assert.True(t, findings[0].Unsupported)
+		assert.Equal(t, "File", findings[0].Node)
+	}
+}
+
+func TestGoFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.go", "sub/b.go", "sub/c.txt", "testdata/d.go", ".hidden/e.go"} {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte("package p\n"), 0644))
+	}
+	files, err := GoFiles([]string{dir + "/..."})
+	assert.Nil(t, err)
+	for i := range files {
+		files[i] = filepath.ToSlash(strings.TrimPrefix(files[i], dir+string(filepath.Separator)))
+	}
+	assert.Equal(t, []string{"a.go", "sub/b.go"}, files)
+
+	files, err = GoFiles([]string{filepath.Join(dir, "sub")})
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	b, err := SARIF([]Finding{{File: "a.go", Warning: Warning{Position: Position{Line: 2, Column: 3}, Node: "BasicLit", Message: "m"}, Unsupported: true}})
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"ruleId": "unsupported"`)
+	assert.Contains(t, string(b), `"startLine": 2`)
+}
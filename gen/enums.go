@@ -0,0 +1,177 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// enumMembers is a type with a const block of members whose generator takes
+// the name of the type and a slice of Member instead of the members of the
+// source
+type enumMembers struct {
+	name    string
+	decl    *ast.GenDecl
+	consts  *ast.GenDecl
+	members []string
+	// values are the strings of the members that are not derived from their
+	// names, found in the switches and literals listing the members
+	values []string
+	// data is the variable holding the members of the source
+	data string
+}
+
+// enumsTemplate returns the template of a file whose enums listed in names
+// are generated from a slice of Member. The const block of the members is
+// generated from the slice, and so are the runs of cases, elements and
+// statements with one element for every member in the declarations that
+// mention the type or its members.
+func (cv *converter) enumsTemplate(f *ast.File, names []string) *template {
+	t := newTemplate()
+	var lower bool
+	var enums []*enumMembers
+	for _, decl := range f.Decls {
+		if e := findEnum(decl, f.Decls, names); e != nil {
+			enums = append(enums, e)
+		}
+	}
+	if len(enums) == 0 {
+		return t
+	}
+
+	params := []jen.Code{jen.Id("name").String(), jen.Id("members").Index().Id("Member")}
+	var plan []entry
+	for _, decl := range f.Decls {
+		e := enumRelated(decl, enums)
+		if e == nil {
+			plan = append(plan, entry{node: decl})
+			continue
+		}
+		if decl == e.consts {
+			e.constPlan(t)
+		} else {
+			e.series().plans(t, decl, "member", jen.Id("members"), &lower)
+		}
+		typeNames(t, decl, typeSubs(e.name, &lower))
+		plan = append(plan, entry{
+			node:   decl,
+			params: params,
+			args:   []jen.Code{jen.Lit(e.name), jen.Id(e.data)},
+		})
+	}
+	t.plans[f] = plan
+
+	t.decls = append(t.decls, genMemberType())
+	for _, e := range enums {
+		t.decls = append(t.decls, e.genData())
+	}
+	if lower {
+		t.decls = append(t.decls, genLowerFirst())
+	}
+	return t
+}
+
+// findEnum returns the members of the type declared by decl when it is
+// listed in names and decls have a const block of its members, the first
+// member typed and the others repeating it
+func findEnum(decl ast.Decl, decls []ast.Decl, names []string) *enumMembers {
+	g, ok := decl.(*ast.GenDecl)
+	if !ok || g.Tok != token.TYPE || len(g.Specs) != 1 {
+		return nil
+	}
+	name := g.Specs[0].(*ast.TypeSpec).Name.String()
+	if !contains(names, name) {
+		return nil
+	}
+	for _, d := range decls {
+		c, ok := d.(*ast.GenDecl)
+		if !ok || c.Tok != token.CONST || len(c.Specs) < 2 || !enumConsts(c, name) {
+			continue
+		}
+		e := &enumMembers{name: name, decl: g, consts: c, data: lowerFirst(name) + "Members"}
+		for _, spec := range c.Specs {
+			e.members = append(e.members, spec.(*ast.ValueSpec).Names[0].String())
+		}
+		return e
+	}
+	return nil
+}
+
+func enumConsts(c *ast.GenDecl, name string) bool {
+	for i, spec := range c.Specs {
+		s := spec.(*ast.ValueSpec)
+		if len(s.Names) != 1 || s.Names[0].Name == "_" {
+			return false
+		}
+		if i == 0 {
+			id, ok := s.Type.(*ast.Ident)
+			if !ok || id.Name != name || len(s.Values) != 1 {
+				return false
+			}
+		} else if s.Type != nil || len(s.Values) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// enumRelated returns the enum a declaration belongs to, the type itself,
+// its const block, its methods and declarations mentioning it or its members
+func enumRelated(decl ast.Decl, enums []*enumMembers) *enumMembers {
+	for _, e := range enums {
+		if decl == e.decl || decl == e.consts || recvType(decl) == e.name {
+			return e
+		}
+	}
+	if g, ok := decl.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+		return nil
+	}
+	for _, e := range enums {
+		for _, l := range leaves(decl) {
+			if id, ok := l.(*ast.Ident); ok && (id.Name == e.name || contains(e.members, id.Name)) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (e *enumMembers) series() series {
+	return series{typ: e.name, names: e.members, values: &e.values}
+}
+
+// constPlan generates the first member of the const block with its type and
+// value from the first Member and the others from the remaining Members
+func (e *enumMembers) constPlan(t *template) {
+	first := e.consts.Specs[0].(*ast.ValueSpec)
+	rest := e.consts.Specs[1].(*ast.ValueSpec)
+	members := jen.Id("members")
+	t.useLeaf(first.Names[0], identUse, members.Clone().Index(jen.Lit(0)).Dot("Name"))
+	t.useLeaf(rest.Names[0], identUse, jen.Id("member").Dot("Name"))
+	t.plans[e.consts] = []entry{
+		{node: first},
+		{node: rest, rng: members.Clone().Index(jen.Lit(1), jen.Empty()), v: "member"},
+	}
+}
+
+func genMemberType() jen.Code {
+	return jen.Comment("Member describes a member of a generated enum").Line().
+		Type().Id("Member").Struct(
+		jen.Id("Name").String(),
+		jen.Id("Value").String(),
+	)
+}
+
+// genData generates the variable holding the members of the source enum
+func (e *enumMembers) genData() jen.Code {
+	var members []jen.Code
+	for i, m := range e.members {
+		d := jen.Dict{jen.Id("Name"): jen.Lit(m)}
+		if e.values != nil {
+			d[jen.Id("Value")] = jen.Lit(e.values[i])
+		}
+		members = append(members, jen.Values(d))
+	}
+	return jen.Var().Id(e.data).Op("=").Index().Id("Member").Values(members...)
+}
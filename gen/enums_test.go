@@ -0,0 +1,80 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var enumsTests = []tcg{
+	tcg{
+		"string and values",
+		`package color
+
+import "fmt"
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+var colorNames = map[Color]string{Red: "red", Green: "green", Blue: "blue"}
+
+func (c Color) String() string {
+	switch c {
+	case Red:
+		return "Red"
+	case Green:
+		return "Green"
+	case Blue:
+		return "Blue"
+	}
+	return fmt.Sprintf("Color(%d)", int(c))
+}
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(colorNames[c]), nil
+}
+func ColorValues() []Color {
+	return []Color{Red, Green, Blue}
+}
+`,
+	},
+	tcg{
+		"member values",
+		`package color
+
+type Color int
+
+const (
+	Red Color = iota + 1
+	Green
+	Blue
+)
+
+func (c Color) Hex() string {
+	switch c {
+	case Red:
+		return "#f00"
+	case Green:
+		return "#0f0"
+	case Blue:
+		return "#00f"
+	}
+	return ""
+}
+`,
+	},
+}
+
+func TestEnums(t *testing.T) {
+	for _, tc := range enumsTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			gen := roundTrip(t, test.Code, Options{Enums: []string{"Color"}})
+			assert.Contains(t, gen, "range members")
+		})
+	}
+}
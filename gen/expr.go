@@ -7,182 +7,191 @@ import (
 	"github.com/dave/jennifer/jen"
 )
 
-func genExprs(s []ast.Expr) jen.Code {
+func (cv *converter) genExprs(s []ast.Expr) jen.Code {
 	if len(s) == 0 {
 		return jen.Null()
 	}
 	if len(s) == 1 {
-		return genExpr(s[0])
+		return cv.genExpr(s[0])
 	}
-	code := genExprsCode(s)
+	code := cv.genExprsCode(s)
 	return jen.Dot("List").Call(code...)
 }
 
-func genExprsCode(s []ast.Expr) []jen.Code {
+func (cv *converter) genExprsCode(s []ast.Expr) []jen.Code {
 	var code []jen.Code
 	for _, expr := range s {
-		code = append(code, jen.Id("jen").Add(genExpr(expr)))
+		code = append(code, jen.Id("jen").Add(cv.genExpr(expr)))
 	}
 	return code
 }
 
-func genExpr(s ast.Expr) jen.Code {
+func (cv *converter) genExpr(s ast.Expr) (ret jen.Code) {
 	if s == nil {
 		return jen.Null()
 	}
+	if cv.lenient {
+		defer cv.recoverVerbatim(s, &ret, false)
+	}
+	if c, ok := cv.subst(s); ok {
+		return c
+	}
 	switch t := s.(type) {
 	case *ast.Ident:
-		return ident(t)
+		return cv.ident(t)
 	case *ast.Ellipsis:
-		return ellipsis(t)
+		return cv.ellipsis(t)
 	case *ast.BasicLit:
-		return basicLit(t)
+		return cv.basicLit(t)
 	case *ast.FuncLit:
-		return funcLit(t)
+		return cv.funcLit(t)
 	case *ast.CompositeLit:
-		return compositeLit(t)
+		return cv.compositeLit(t)
 	case *ast.ParenExpr:
-		return parenExpr(t)
+		return cv.parenExpr(t)
 	case *ast.SelectorExpr:
-		return selectorExpr(t)
+		return cv.selectorExpr(t)
 	case *ast.IndexExpr:
-		return indexExpr(t)
+		return cv.indexExpr(t)
 	case *ast.SliceExpr:
-		return sliceExpr(t)
+		return cv.sliceExpr(t)
 	case *ast.TypeAssertExpr:
-		return typeAssertExpr(t)
+		return cv.typeAssertExpr(t)
 	case *ast.CallExpr:
-		return callExpr(t)
+		return cv.callExpr(t)
 	case *ast.StarExpr:
-		return starExpr(t)
+		return cv.starExpr(t)
 	case *ast.UnaryExpr:
-		return unaryExpr(t)
+		return cv.unaryExpr(t)
 	case *ast.BinaryExpr:
-		return binaryExpr(t)
+		return cv.binaryExpr(t)
 	case *ast.KeyValueExpr:
-		return keyValueExpr(t)
+		return cv.keyValueExpr(t)
 	case *ast.ArrayType:
-		return arrayType(t)
+		return cv.arrayType(t)
 	case *ast.StructType:
-		return structType(t)
+		return cv.structType(t)
 	case *ast.FuncType:
-		return funcType(t)
+		return jen.Dot("Func").Call().Add(cv.funcType(t))
 	case *ast.InterfaceType:
-		return interfaceType(t)
+		return cv.interfaceType(t)
 	case *ast.MapType:
-		return mapType(t)
+		return cv.mapType(t)
 	case *ast.ChanType:
-		return chanType(t)
+		return cv.chanType(t)
 	}
 	panic("Not Handled gen expr: " + reflect.TypeOf(s).String() + " at " + string(s.Pos()))
 }
-func ellipsis(t *ast.Ellipsis) jen.Code {
-	return jen.Dot("Op").Call(jen.Lit("...")).Add(genExpr(t.Elt))
+func (cv *converter) ellipsis(t *ast.Ellipsis) jen.Code {
+	return jen.Dot("Op").Call(jen.Lit("...")).Add(cv.genExpr(t.Elt))
 }
 
-func funcLit(t *ast.FuncLit) jen.Code {
-	return jen.Dot("Func").Call().Add(funcType(t.Type)).Add(blockStmt(t.Body))
+func (cv *converter) funcLit(t *ast.FuncLit) jen.Code {
+	return jen.Dot("Func").Call().Add(cv.funcType(t.Type)).Add(cv.blockStmt(t.Body))
 }
 
-func compositeLit(t *ast.CompositeLit) jen.Code {
-	return jen.Add(genExpr(t.Type)).Dot("Values").Call(genExprsCode(t.Elts)...)
+func (cv *converter) compositeLit(t *ast.CompositeLit) jen.Code {
+	return jen.Add(cv.genExpr(t.Type)).Add(cv.group(t, "Values", exprNodes(t.Elts), cv.exprCode))
 }
 
-func parenExpr(t *ast.ParenExpr) jen.Code {
-	return jen.Dot("Parens").Call(jen.Id("jen").Add(genExpr(t.X)))
+func (cv *converter) parenExpr(t *ast.ParenExpr) jen.Code {
+	return jen.Dot("Parens").Call(jen.Id("jen").Add(cv.genExpr(t.X)))
 }
 
-func indexExpr(t *ast.IndexExpr) jen.Code {
-	return jen.Add(genExpr(t.X)).Dot("Index").Call(jen.Id("jen").Add(genExpr(t.Index)))
+func (cv *converter) indexExpr(t *ast.IndexExpr) jen.Code {
+	return jen.Add(cv.genExpr(t.X)).Dot("Index").Call(jen.Id("jen").Add(cv.genExpr(t.Index)))
 }
-func starExpr(t *ast.StarExpr) jen.Code {
-	return jen.Dot("Op").Call(jen.Lit("*")).Add(genExpr(t.X))
+func (cv *converter) starExpr(t *ast.StarExpr) jen.Code {
+	return jen.Dot("Op").Call(jen.Lit("*")).Add(cv.genExpr(t.X))
 }
-func unaryExpr(t *ast.UnaryExpr) jen.Code {
-	return jen.Dot("Op").Call(jen.Lit(t.Op.String())).Add(genExpr(t.X))
+func (cv *converter) unaryExpr(t *ast.UnaryExpr) jen.Code {
+	return jen.Dot("Op").Call(jen.Lit(t.Op.String())).Add(cv.genExpr(t.X))
 }
-func binaryExpr(t *ast.BinaryExpr) jen.Code {
-	return jen.Add(genExpr(t.X)).Dot("Op").Call(jen.Lit(t.Op.String())).Add(genExpr(t.Y))
+func (cv *converter) binaryExpr(t *ast.BinaryExpr) jen.Code {
+	return jen.Add(cv.genExpr(t.X)).Dot("Op").Call(jen.Lit(t.Op.String())).Add(cv.genExpr(t.Y))
 }
 
-func keyValueExpr(t *ast.KeyValueExpr) jen.Code {
-	ret := jen.Add(genExpr(t.Key)).Dot("Op").Call(jen.Lit(":")).Add(genExpr(t.Value))
+func (cv *converter) keyValueExpr(t *ast.KeyValueExpr) jen.Code {
+	ret := jen.Add(cv.genExpr(t.Key)).Dot("Op").Call(jen.Lit(":")).Add(cv.genExpr(t.Value))
 	return ret
 }
 
-func mapType(t *ast.MapType) jen.Code {
+func (cv *converter) mapType(t *ast.MapType) jen.Code {
 	ret := jen.Dot("Map").Call(
-		jen.Id("jen").Add(genExpr(t.Key)),
-	).Add(genExpr(t.Value))
+		jen.Id("jen").Add(cv.genExpr(t.Key)),
+	).Add(cv.genExpr(t.Value))
 	return ret
 }
 
-func selectorExpr(t *ast.SelectorExpr) jen.Code {
+func (cv *converter) selectorExpr(t *ast.SelectorExpr) jen.Code {
 	dent, ok := t.X.(*ast.Ident)
 	if ok {
-		path, ok := paths[dent.String()]
+		path, ok := cv.paths[dent.String()]
 		if ok {
-			return jen.Dot("Qual").Call(jen.Lit(path), jen.Lit(t.Sel.String()))
+			return jen.Dot("Qual").Call(jen.Lit(path), cv.name(t.Sel))
 		}
 	}
-	return jen.Add(genExpr(t.X)).Dot("Dot").Call(jen.Lit(t.Sel.String()))
+	return jen.Add(cv.genExpr(t.X)).Dot("Dot").Call(cv.name(t.Sel))
 }
 
-func identsList(s []*ast.Ident) jen.Code {
+func (cv *converter) identsList(s []*ast.Ident) jen.Code {
 	if len(s) == 0 {
 		return jen.Null()
 	}
 	if len(s) == 1 {
-		return ident(s[0])
+		return cv.ident(s[0])
 	}
 	var n []jen.Code
 	for _, name := range s {
-		n = append(n, jen.Id("jen").Add(ident(name)))
+		n = append(n, jen.Id("jen").Add(cv.ident(name)))
 	}
 	return jen.Dot("List").Call(jen.List(n...))
 }
 
-func ident(s *ast.Ident) jen.Code {
-	return jen.Dot("Id").Call(jen.Lit(s.String()))
+func (cv *converter) ident(s *ast.Ident) jen.Code {
+	return jen.Dot("Id").Call(cv.name(s))
 }
 
-func typeAssertExpr(t *ast.TypeAssertExpr) jen.Code {
-	ret2 := jen.Add(genExpr(t.X)).Dot("Assert")
+func (cv *converter) typeAssertExpr(t *ast.TypeAssertExpr) jen.Code {
+	ret2 := jen.Add(cv.genExpr(t.X)).Dot("Assert")
 	if t.Type == nil {
 		return ret2.Call(jen.Id("jen").Dot("Type").Call())
 	}
-	return ret2.Call(jen.Id("jen").Add(genExpr(t.Type)))
+	return ret2.Call(jen.Id("jen").Add(cv.genExpr(t.Type)))
 }
 
-func callExpr(t *ast.CallExpr) jen.Code {
-	args := genExprsCode(t.Args)
+func (cv *converter) callExpr(t *ast.CallExpr) jen.Code {
+	if args, ok := cv.callArgs(t); ok {
+		return jen.Add(cv.genExpr(t.Fun)).Dot("Call").Call(args)
+	}
+	args := cv.genExprsCode(t.Args)
 	if t.Ellipsis.IsValid() {
 		args[len(args)-1] = jen.Add(args[len(args)-1]).Dot("Op").Call(jen.Lit("..."))
 	}
-	return jen.Add(genExpr(t.Fun)).Dot("Call").Call(args...)
+	return jen.Add(cv.genExpr(t.Fun)).Dot("Call").Call(args...)
 }
 
-func sliceExpr(t *ast.SliceExpr) jen.Code {
+func (cv *converter) sliceExpr(t *ast.SliceExpr) jen.Code {
 	code := []jen.Code{
 		jen.Id("jen").Dot("Empty").Call(),
 		jen.Id("jen").Dot("Empty").Call(),
 	}
 	if t.Low != nil {
-		code[0] = jen.Id("jen").Add(genExpr(t.Low))
+		code[0] = jen.Id("jen").Add(cv.genExpr(t.Low))
 	}
 	if t.High != nil {
-		code[1] = jen.Id("jen").Add(genExpr(t.High))
+		code[1] = jen.Id("jen").Add(cv.genExpr(t.High))
 	}
 	if t.Slice3 {
 		code = append(code, jen.Id("jen").Dot("Empty").Call())
 		if t.Max != nil {
-			code[2] = jen.Id("jen").Add(genExpr(t.Max))
+			code[2] = jen.Id("jen").Add(cv.genExpr(t.Max))
 		}
 	}
-	return jen.Add(genExpr(t.X)).Dot("Index").Call(code...)
+	return jen.Add(cv.genExpr(t.X)).Dot("Index").Call(code...)
 }
 
-func chanType(t *ast.ChanType) jen.Code {
+func (cv *converter) chanType(t *ast.ChanType) jen.Code {
 	ret2 := jen.Null()
 	if t.Arrow.IsValid() {
 		ret2.Dot("Op")
@@ -195,5 +204,5 @@ func chanType(t *ast.ChanType) jen.Code {
 	} else {
 		ret2.Dot("Chan").Call()
 	}
-	return ret2.Add(genExpr(t.Value))
+	return ret2.Add(cv.genExpr(t.Value))
 }
@@ -0,0 +1,170 @@
+package gen
+
+import (
+	"go/ast"
+	"strconv"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// factorRun is the least number of similar siblings factored into a loop
+const factorRun = 3
+
+// factor makes the generator build runs of similar siblings, that only differ
+// in identifiers and literals, with a loop over a slice holding the
+// differences. Lists and leaves already changed by t are left alone.
+func (t *template) factor(cv *converter, f *ast.File) {
+	done := map[ast.Node]bool{}
+	count := map[string]int{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || done[n] {
+			return false
+		}
+		if _, ok := t.substs[n]; ok {
+			return false
+		}
+		list := factorList(n)
+		if len(list) < factorRun || t.plans[n] != nil {
+			return true
+		}
+		var shapes []string
+		for _, e := range list {
+			shapes = append(shapes, shape(e))
+		}
+		var plan []entry
+		found := false
+		for k := 0; k < len(list); {
+			end := k + 1
+			for end < len(list) && shapes[end] == shapes[k] {
+				end++
+			}
+			if end-k >= factorRun {
+				if e, ok := t.factorRun(cv, n, list[k:end], count); ok {
+					plan = append(plan, e)
+					for _, el := range list[k:end] {
+						done[el] = true
+					}
+					k = end
+					found = true
+					continue
+				}
+			}
+			plan = append(plan, entry{node: list[k]})
+			k++
+		}
+		if found {
+			t.plans[n] = plan
+		}
+		return true
+	})
+}
+
+// factorList returns the elements of the lists generated with group
+func factorList(n ast.Node) []ast.Node {
+	switch c := n.(type) {
+	case *ast.BlockStmt:
+		return stmtNodes(c.List)
+	case *ast.CaseClause:
+		return stmtNodes(c.Body)
+	case *ast.CommClause:
+		return stmtNodes(c.Body)
+	case *ast.CompositeLit:
+		return exprNodes(c.Elts)
+	case *ast.FieldList:
+		return fieldNodes(c)
+	case *ast.GenDecl:
+		if c.Lparen.IsValid() {
+			return specNodes(c.Specs)
+		}
+	}
+	return nil
+}
+
+// factorRun returns the entry generating elems from a slice of rows, one for
+// every element, with a field for every leaf that differs
+func (t *template) factorRun(cv *converter, parent ast.Node, elems []ast.Node, count map[string]int) (entry, bool) {
+	var cols [][]ast.Node
+	for _, e := range elems {
+		cols = append(cols, leaves(e))
+	}
+	rows := make([]jen.Dict, len(elems))
+	var fields []jen.Code
+	var uses []func()
+	names := map[string]int{}
+	for j, l := range cols[0] {
+		var vals []string
+		_, use := leafValue(l)
+		for i := range cols {
+			if t.changed(cols[i][j]) {
+				return entry{}, false
+			}
+			val, u := leafValue(cols[i][j])
+			if u != use || use == codeUse {
+				return entry{}, false
+			}
+			vals = append(vals, val)
+		}
+		if same(vals) {
+			continue
+		}
+		for _, val := range vals {
+			if _, ok := cv.paths[val]; ok && use == identUse {
+				// package names are generated with Qual
+				return entry{}, false
+			}
+		}
+		field := map[useKind]string{identUse: "Name", stringUse: "Text", intUse: "Num", litUse: "Lit"}[use]
+		names[field]++
+		if names[field] > 1 {
+			field += strconv.Itoa(names[field])
+		}
+		typ := jen.String()
+		if use == intUse {
+			typ = jen.Int()
+		}
+		fields = append(fields, jen.Id(field).Add(typ))
+		for i, val := range vals {
+			if rows[i] == nil {
+				rows[i] = jen.Dict{}
+			}
+			if use == intUse {
+				v, _ := strconv.Atoi(val)
+				rows[i][jen.Id(field)] = jen.Lit(v)
+			} else {
+				rows[i][jen.Id(field)] = jen.Lit(val)
+			}
+		}
+		l, use := l, use
+		uses = append(uses, func() {
+			t.useLeaf(l, use, jen.Id("row").Dot(field))
+		})
+	}
+	if len(uses) == 0 {
+		return entry{}, false
+	}
+	for _, u := range uses {
+		u()
+	}
+
+	prefix := lowerFirst(t.prefix + containerName(parent))
+	count[prefix]++
+	data := prefix + strconv.Itoa(count[prefix])
+	var values []jen.Code
+	for _, row := range rows {
+		values = append(values, jen.Values(row))
+	}
+	t.decls = append(t.decls, jen.Var().Id(data).Op("=").Index().Struct(fields...).Values(values...))
+	return entry{node: elems[0], rng: jen.Id(data), v: "row"}, true
+}
+
+// changed reports whether the code of a leaf is already replaced
+func (t *template) changed(l ast.Node) bool {
+	if _, ok := t.substs[l]; ok {
+		return true
+	}
+	if id, ok := l.(*ast.Ident); ok {
+		_, ok := t.names[id]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var factorTests = []tcg{
+	tcg{
+		"struct fields",
+		`package main
+
+type Config struct {
+	Host    string
+	Port    int
+	Timeout int
+	Debug   bool
+}
+`,
+	},
+	tcg{
+		"switch cases",
+		`package main
+
+import "fmt"
+
+func code(s string) int {
+	switch s {
+	case "ok":
+		return 200
+	case "created":
+		return 201
+	case "missing":
+		return 404
+	}
+	fmt.Println("unknown", s)
+	return 500
+}
+`,
+	},
+	tcg{
+		"map entries",
+		`package main
+
+var units = map[string]float64{"ms": 0.001, "s": 1.0, "m": 60.0, "h": 3600.0}
+`,
+	},
+	tcg{
+		"grouped declarations",
+		`package main
+
+const (
+	a = "a"
+	b = "b"
+	c = "c"
+)
+`,
+	},
+}
+
+func TestFactor(t *testing.T) {
+	for _, tc := range factorTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			gen := roundTrip(t, test.Code, Options{Factor: true})
+			assert.Contains(t, gen, ":= range ")
+		})
+	}
+}
@@ -0,0 +1,654 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// structFields is a struct whose generator takes its name and fields as
+// parameters instead of the fixed field list of the source
+type structFields struct {
+	name   string
+	decl   *ast.GenDecl
+	fields []fieldInfo
+	// data is the variable holding the fields of the source
+	data string
+	// comments are the trailing comments of the fields, which are kept in
+	// their Comment
+	comments []*ast.CommentGroup
+}
+
+type fieldInfo struct {
+	name    string
+	typ     ast.Expr
+	typStr  string
+	tags    map[string]string
+	comment string
+}
+
+// fieldsTemplate returns the template of a file whose structs listed in
+// names are generated from a slice of Field. Methods of the structs, and
+// functions mentioning them, take the name of the type and its fields as
+// well. Runs of statements, elements or methods with one element for every
+// field that only differ in the field are generated with a loop over the
+// fields.
+func (cv *converter) fieldsTemplate(f *ast.File, names []string) *template {
+	t := newTemplate()
+	var lower bool
+	var structs []*structFields
+	for _, decl := range f.Decls {
+		if s := findStruct(decl, names); s != nil {
+			structs = append(structs, s)
+		}
+	}
+	if len(structs) == 0 {
+		return t
+	}
+
+	params := []jen.Code{jen.Id("name").String(), jen.Id("fields").Index().Id("Field")}
+	var plan []entry
+	decls := f.Decls
+	for k := 0; k < len(decls); k++ {
+		s := related(decls[k], structs)
+		if s == nil {
+			plan = append(plan, entry{node: decls[k]})
+			continue
+		}
+		name := jen.Lit(s.name)
+		if decls[k] == s.decl {
+			t.substs[s.decl] = genStructFields()
+			plan = append(plan, entry{
+				node:   s.decl,
+				name:   "genStruct" + s.name,
+				params: params,
+				args:   []jen.Code{name, jen.Id(s.data)},
+			})
+			continue
+		}
+		if n := len(s.fields); k+n <= len(decls) && s.methods(decls[k:k+n]) {
+			if apply, ok := s.series().window(declNodes(decls[k : k+n])); ok {
+				apply(t, "field", &lower)
+				typeNames(t, decls[k], typeSubs(s.name, &lower))
+				plan = append(plan, entry{
+					node:   decls[k],
+					rng:    jen.Id(s.data),
+					v:      "field",
+					typ:    jen.Id("Field"),
+					params: []jen.Code{jen.Id("name").String()},
+					args:   []jen.Code{name},
+				})
+				k += n - 1
+				continue
+			}
+		}
+		s.series().plans(t, decls[k], "field", jen.Id("fields"), &lower)
+		typeNames(t, decls[k], typeSubs(s.name, &lower))
+		plan = append(plan, entry{
+			node:   decls[k],
+			params: params,
+			args:   []jen.Code{name, jen.Id(s.data)},
+		})
+	}
+	t.plans[f] = plan
+
+	t.decls = append(t.decls, genFieldType(), genFieldFunc())
+	for _, s := range structs {
+		t.decls = append(t.decls, s.genData(cv))
+		for _, c := range s.comments {
+			t.comments[c] = true
+		}
+	}
+	if lower {
+		t.decls = append(t.decls, genLowerFirst())
+	}
+	return t
+}
+
+// findStruct returns the fields of decl when it declares a single struct
+// listed in names
+func findStruct(decl ast.Decl, names []string) *structFields {
+	g, ok := decl.(*ast.GenDecl)
+	if !ok || g.Tok != token.TYPE || len(g.Specs) != 1 {
+		return nil
+	}
+	spec := g.Specs[0].(*ast.TypeSpec)
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok || !contains(names, spec.Name.String()) {
+		return nil
+	}
+	s := &structFields{name: spec.Name.String(), decl: g, data: lowerFirst(spec.Name.String()) + "Fields"}
+	for _, field := range st.Fields.List {
+		info := fieldInfo{typ: field.Type, typStr: types.ExprString(field.Type)}
+		if field.Tag != nil {
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			info.tags = parseTags(tag)
+		}
+		if field.Comment != nil {
+			info.comment = strings.TrimSpace(field.Comment.Text())
+			s.comments = append(s.comments, field.Comment)
+		}
+		if len(field.Names) == 0 {
+			s.fields = append(s.fields, info)
+		}
+		for _, name := range field.Names {
+			info.name = name.String()
+			s.fields = append(s.fields, info)
+		}
+	}
+	return s
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTags splits a struct tag into its keys and values the way
+// reflect.StructTag does
+func parseTags(tag string) map[string]string {
+	ret := map[string]string{}
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := tag[:i]
+		tag = tag[i+1:]
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			break
+		}
+		ret[name] = value
+		tag = tag[i+1:]
+	}
+	return ret
+}
+
+// related returns the struct a declaration belongs to, the struct itself, its
+// methods and functions that mention it
+func related(decl ast.Decl, structs []*structFields) *structFields {
+	for _, s := range structs {
+		if decl == s.decl || recvType(decl) == s.name {
+			return s
+		}
+	}
+	if _, ok := decl.(*ast.FuncDecl); !ok {
+		return nil
+	}
+	for _, s := range structs {
+		for _, l := range leaves(decl) {
+			if id, ok := l.(*ast.Ident); ok && id.Name == s.name {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+func recvType(decl ast.Decl) string {
+	f, ok := decl.(*ast.FuncDecl)
+	if !ok || f.Recv == nil || len(f.Recv.List) == 0 {
+		return ""
+	}
+	typ := f.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// methods reports whether every declaration is a method of the struct
+func (s *structFields) methods(decls []ast.Decl) bool {
+	for _, d := range decls {
+		if recvType(d) != s.name {
+			return false
+		}
+	}
+	return true
+}
+
+func declNodes(decls []ast.Decl) []ast.Node {
+	var ret []ast.Node
+	for _, d := range decls {
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// series is a list of names, such as the fields of a struct or the members
+// of an enum, that runs of elements with an element for every name are
+// generated from
+type series struct {
+	// typ is the name of the type the names belong to
+	typ   string
+	names []string
+	// types are the printed types of the names, nil when they have none
+	types []string
+	// values are the strings of the names that can not be derived from the
+	// names. It is nil when the series has no values and points to nil until
+	// a run needs them.
+	values *[]string
+}
+
+func (s *structFields) series() series {
+	sr := series{typ: s.name}
+	for _, f := range s.fields {
+		sr.names = append(sr.names, f.name)
+		sr.types = append(sr.types, f.typStr)
+	}
+	return sr
+}
+
+// plans generates the lists of decl that have a run of elements for the
+// names with a loop over rng binding each to v
+func (sr series) plans(t *template, decl ast.Node, v string, rng jen.Code, lower *bool) {
+	done := map[ast.Node]bool{}
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n == nil || done[n] {
+			return false
+		}
+		var list []ast.Node
+		switch c := n.(type) {
+		case *ast.BlockStmt:
+			list = stmtNodes(c.List)
+		case *ast.CaseClause:
+			list = stmtNodes(c.Body)
+		case *ast.CommClause:
+			list = stmtNodes(c.Body)
+		case *ast.CompositeLit:
+			list = exprNodes(c.Elts)
+		case *ast.GenDecl:
+			if !c.Lparen.IsValid() {
+				return true
+			}
+			list = specNodes(c.Specs)
+		default:
+			return true
+		}
+		if len(sr.names) == 0 {
+			return true
+		}
+		var plan []entry
+		found := false
+		for k := 0; k < len(list); k++ {
+			if n := len(sr.names); k+n <= len(list) {
+				if apply, ok := sr.window(list[k : k+n]); ok {
+					apply(t, v, lower)
+					plan = append(plan, entry{node: list[k], rng: rng, v: v})
+					for _, e := range list[k : k+n] {
+						done[e] = true
+					}
+					k += n - 1
+					found = true
+					continue
+				}
+			}
+			plan = append(plan, entry{node: list[k]})
+		}
+		if found {
+			t.plans[n] = plan
+		}
+		return true
+	})
+}
+
+func (sr series) mask(i int) func(ast.Node) bool {
+	if sr.types == nil {
+		return nil
+	}
+	return typeMask(sr.types[i])
+}
+
+// window reports whether elems has an element for every name that only
+// differs from the others in the name, its type and its value. The returned
+// function makes the generator build the first element from the Name, Type
+// and Value of a variable.
+func (sr series) window(elems []ast.Node) (func(t *template, v string, lower *bool), bool) {
+	for _, name := range sr.names {
+		if name == "" {
+			return nil, false
+		}
+	}
+	sh := maskedShape(elems[0], sr.mask(0))
+	var cols [][]ast.Node
+	for i, e := range elems {
+		if maskedShape(e, sr.mask(i)) != sh {
+			return nil, false
+		}
+		cols = append(cols, maskedLeaves(e, sr.mask(i)))
+	}
+	var values []string
+	var uses []func(t *template, v string, lower *bool)
+	for j, l := range cols[0] {
+		if sr.isType(cols, j) {
+			l := l
+			uses = append(uses, func(t *template, v string, _ *bool) {
+				t.substs[l] = jen.Dot("Add").Call(jen.Id(v).Dot("Type"))
+			})
+			continue
+		}
+		var vals []string
+		_, use := leafValue(l)
+		for i := range cols {
+			val, u := leafValue(cols[i][j])
+			if u != use {
+				return nil, false
+			}
+			vals = append(vals, val)
+		}
+		if same(vals) {
+			continue
+		}
+		if use != identUse && use != stringUse {
+			return nil, false
+		}
+		l, use := l, use
+		prefix, suffix, low, ok := sr.text(vals)
+		if !ok {
+			if use != stringUse || !sr.valued(values, vals) {
+				return nil, false
+			}
+			values = vals
+			uses = append(uses, func(t *template, v string, _ *bool) {
+				t.useLeaf(l, use, jen.Id(v).Dot("Value"))
+			})
+			continue
+		}
+		uses = append(uses, func(t *template, v string, lower *bool) {
+			name := jen.Id(v).Dot("Name")
+			if low {
+				*lower = true
+				name = jen.Id("lowerFirst").Call(name)
+			}
+			subs := typeSubs(sr.typ, lower)
+			parts, _ := textParts(prefix, subs, use == stringUse)
+			parts = append(parts, name)
+			suffix, _ := textParts(suffix, subs, use == stringUse)
+			t.useLeaf(l, use, joinCode(append(parts, suffix...)))
+		})
+	}
+	if len(uses) == 0 {
+		return nil, false
+	}
+	if values != nil {
+		*sr.values = values
+	}
+	return func(t *template, v string, lower *bool) {
+		for _, u := range uses {
+			u(t, v, lower)
+		}
+	}, true
+}
+
+// valued reports whether vals can be the values of the series, found is
+// the values found so far in the run
+func (sr series) valued(found, vals []string) bool {
+	switch {
+	case sr.values == nil:
+		return false
+	case found != nil:
+		return equalStrings(found, vals)
+	case *sr.values != nil:
+		return equalStrings(*sr.values, vals)
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isType reports whether the leaves at j are the types of the names
+func (sr series) isType(cols [][]ast.Node, j int) bool {
+	if sr.types == nil {
+		return false
+	}
+	for i := range cols {
+		if !sr.mask(i)(cols[i][j]) {
+			return false
+		}
+	}
+	return true
+}
+
+func same(vals []string) bool {
+	for _, v := range vals {
+		if v != vals[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// text finds the constant prefix and suffix around the names in vals, lower
+// is set when the first letter of the names is lowered
+func (sr series) text(vals []string) (string, string, bool, bool) {
+	for _, lower := range []bool{false, true} {
+		name := func(i int) string {
+			if lower {
+				return lowerFirst(sr.names[i])
+			}
+			return sr.names[i]
+		}
+		v, n := vals[0], name(0)
+		for idx := strings.Index(v, n); idx != -1; idx = nextIndex(v, n, idx) {
+			prefix, suffix := v[:idx], v[idx+len(n):]
+			ok := true
+			for i := range vals {
+				if vals[i] != prefix+name(i)+suffix {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				return prefix, suffix, lower, true
+			}
+		}
+	}
+	return "", "", false, false
+}
+
+// typeMask is true for the expressions printed as typ
+func typeMask(typ string) func(ast.Node) bool {
+	return func(n ast.Node) bool {
+		e, ok := n.(ast.Expr)
+		return ok && types.ExprString(e) == typ
+	}
+}
+
+// typeSubs replaces the name of a type with the name the generator takes
+func typeSubs(name string, lower *bool) []textSub {
+	subs := []textSub{{val: name, code: jen.Id("name")}}
+	if l := lowerFirst(name); l != name {
+		subs = append(subs, textSub{val: l, code: jen.Id("lowerFirst").Call(jen.Id("name")), used: lower})
+	}
+	return subs
+}
+
+// typeNames makes the generator use subs for the identifiers and strings of
+// decl that contain them
+func typeNames(t *template, decl ast.Decl, subs []textSub) {
+	for _, l := range leaves(decl) {
+		if _, ok := t.substs[l]; ok {
+			continue
+		}
+		switch n := l.(type) {
+		case *ast.Ident:
+			if _, ok := t.names[n]; ok {
+				continue
+			}
+			if parts, ok := textParts(n.Name, subs, false); ok {
+				t.useLeaf(n, identUse, joinCode(parts))
+			}
+		case *ast.BasicLit:
+			val, use := leafValue(n)
+			if use != stringUse {
+				continue
+			}
+			if parts, ok := textParts(val, subs, true); ok {
+				t.useLeaf(n, stringUse, joinCode(parts))
+			}
+		}
+	}
+}
+
+// textSub replaces the occurrences of val in a string with code. used is set
+// when the replacement is made. With camel val is not replaced when a lower
+// case letter follows it, so that Get is found in GetFunc but not in Getenv.
+type textSub struct {
+	val   string
+	code  jen.Code
+	used  *bool
+	camel bool
+}
+
+// textParts splits s into literal parts and the code of the substitutions
+// found in s, ok is set if there were any. With words only whole words are
+// replaced.
+func textParts(s string, subs []textSub, words bool) (parts []jen.Code, ok bool) {
+	lit := ""
+	flush := func() {
+		if lit != "" {
+			parts = append(parts, jen.Lit(lit))
+			lit = ""
+		}
+	}
+	for i := 0; i < len(s); {
+		matched := false
+		for _, sub := range subs {
+			if !strings.HasPrefix(s[i:], sub.val) {
+				continue
+			}
+			end := i + len(sub.val)
+			if words && ((i > 0 && isWordByte(s[i-1])) || (end < len(s) && isWordByte(s[end]))) {
+				continue
+			}
+			flush()
+			parts = append(parts, sub.code)
+			if sub.used != nil {
+				*sub.used = true
+			}
+			i = end
+			matched, ok = true, true
+			break
+		}
+		if !matched {
+			lit += s[i : i+1]
+			i++
+		}
+	}
+	flush()
+	return parts, ok
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
+
+// joinCode concatenates string code with +
+func joinCode(parts []jen.Code) jen.Code {
+	if len(parts) == 0 {
+		return jen.Lit("")
+	}
+	ret := jen.Add(parts[0])
+	for _, part := range parts[1:] {
+		ret.Op("+").Add(part)
+	}
+	return ret
+}
+
+// genStructFields generates the struct declaration of a struct template
+func genStructFields() jen.Code {
+	return jen.Qual(jenImp, "Type").Call().Dot("Id").Call(jen.Id("name")).Dot("StructFunc").Call(
+		jen.Func().Params(jen.Id("g").Op("*").Qual(jenImp, "Group")).Block(
+			jen.For(jen.List(jen.Id("_"), jen.Id("field")).Op(":=").Range().Id("fields")).Block(
+				jen.Id("g").Dot("Add").Call(jen.Id("genField").Call(jen.Id("field"))),
+			),
+		),
+	)
+}
+
+func genFieldType() jen.Code {
+	return jen.Comment("Field describes a field of a generated struct").Line().
+		Type().Id("Field").Struct(
+		jen.Id("Name").String(),
+		jen.Id("Type").Qual(jenImp, "Code"),
+		jen.Id("Tags").Map(jen.String()).String(),
+		jen.Id("Comment").String(),
+	)
+}
+
+func genFieldFunc() jen.Code {
+	return jen.Func().Id("genField").Params(jen.Id("f").Id("Field")).Qual(jenImp, "Code").Block(
+		jen.Id("ret").Op(":=").Qual(jenImp, "Null").Call(),
+		jen.If(jen.Id("f").Dot("Name").Op("!=").Lit("")).Block(
+			jen.Id("ret").Dot("Id").Call(jen.Id("f").Dot("Name")),
+		),
+		jen.Id("ret").Dot("Add").Call(jen.Id("f").Dot("Type")),
+		jen.If(jen.Len(jen.Id("f").Dot("Tags")).Op(">").Lit(0)).Block(
+			jen.Id("ret").Dot("Tag").Call(jen.Id("f").Dot("Tags")),
+		),
+		jen.If(jen.Id("f").Dot("Comment").Op("!=").Lit("")).Block(
+			jen.Id("ret").Dot("Comment").Call(jen.Id("f").Dot("Comment")),
+		),
+		jen.Return().Id("ret"),
+	)
+}
+
+// genData generates the variable holding the fields of the source struct
+func (s *structFields) genData(cv *converter) jen.Code {
+	var fields []jen.Code
+	for _, f := range s.fields {
+		d := jen.Dict{jen.Id("Type"): cv.codeValue(f.typ)}
+		if f.name != "" {
+			d[jen.Id("Name")] = jen.Lit(f.name)
+		}
+		if len(f.tags) > 0 {
+			tags := jen.Dict{}
+			for k, v := range f.tags {
+				tags[jen.Lit(k)] = jen.Lit(v)
+			}
+			d[jen.Id("Tags")] = jen.Map(jen.String()).String().Values(tags)
+		}
+		if f.comment != "" {
+			d[jen.Id("Comment")] = jen.Lit(f.comment)
+		}
+		fields = append(fields, jen.Values(d))
+	}
+	return jen.Var().Id(s.data).Op("=").Index().Id("Field").Values(fields...)
+}
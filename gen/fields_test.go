@@ -0,0 +1,72 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fieldsTests = []tcg{
+	tcg{
+		"struct with getters",
+		`package model
+
+type User struct {
+	Name  string ` + "`db:\"name\" json:\"name\"`" + ` // the name
+	Email string ` + "`json:\"email\"`" + `
+	Age   int
+}
+
+func NewUser() *User {
+	return &User{}
+}
+func (u *User) GetName() string {
+	return u.Name
+}
+func (u *User) GetEmail() string {
+	return u.Email
+}
+func (u *User) GetAge() int {
+	return u.Age
+}
+`,
+	},
+	tcg{
+		"copy and validation",
+		`package model
+
+import "errors"
+
+type User struct {
+	Name  string
+	Email string
+}
+
+func (u *User) Copy() *User {
+	c := &User{}
+	c.Name = u.Name
+	c.Email = u.Email
+	return c
+}
+func (u *User) Validate() error {
+	if u.Name == "" {
+		return errors.New("user: Name is required")
+	}
+	if u.Email == "" {
+		return errors.New("user: Email is required")
+	}
+	return nil
+}
+`,
+	},
+}
+
+func TestFields(t *testing.T) {
+	for _, tc := range fieldsTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			gen := roundTrip(t, test.Code, Options{Fields: []string{"User"}})
+			assert.Contains(t, gen, "range fields")
+		})
+	}
+}
@@ -77,6 +77,39 @@ var tests = []tcg{
 	}
 	`,
 	},
+	tcg{
+		"const block",
+		`package main
+
+	type Color int
+
+	const (
+		Red Color = iota
+		Green
+		Blue
+	)
+	const Max = 3
+	var (
+		a    = 1
+		b, c string
+	)
+	type (
+		T struct{}
+		U = T
+	)
+	func main() {}
+	`,
+	},
+	tcg{
+		"func type",
+		`package main
+
+	type A struct {
+		f func(int) (string, error)
+	}
+	func main() {}
+	`,
+	},
 	tcg{
 		"interface",
 		`package main
@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzGenerateFile(f *testing.F) {
+	for _, tc := range tests {
+		f.Add(tc.Code)
+	}
+	// the generators import jennifer, which is type checked from the
+	// module cache once
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	wd, err := os.Getwd()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		// code that does not parse or is not supported is an error, only a
+		// bug of the converter panics
+		var out []byte
+		var err error
+		for _, opts := range []Options{{}, {Lenient: true}} {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("conversion with %+v panics: %v\n%s", opts, r, src)
+					}
+				}()
+				out, err = GenerateFileBytesWith([]byte(src), "main", true, false, opts)
+			}()
+		}
+		// the lenient generator compiles
+		if err != nil {
+			return
+		}
+		gen, err := parser.ParseFile(fset, filepath.Join(wd, "fuzz_gen.go"), out, 0)
+		if err != nil {
+			t.Fatalf("the generator does not parse: %v\n%s\n%s", err, src, out)
+		}
+		conf := types.Config{Importer: imp}
+		if _, err := conf.Check("main", fset, []*ast.File{gen}, nil); err != nil {
+			t.Fatalf("the generator does not type check: %v\n%s\n%s", err, src, out)
+		}
+	})
+}
+
+func TestConversionErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	src := "package main\n\nvar c = 1i\n"
+	srcPath := filepath.Join(dir, "c.go")
+	assert.Nil(t, ioutil.WriteFile(srcPath, []byte(src), 0644))
+
+	_, err = GenerateFileBytes([]byte(src), "main", true, false)
+	assert.EqualError(t, err, "Cannot parse Imaginary Numbers")
+	_, err = GenerateFileBytesWith([]byte(src), "main", true, false, Options{})
+	assert.EqualError(t, err, "Cannot parse Imaginary Numbers")
+	_, err = InitProject(srcPath, filepath.Join(dir, "cgen"), "", Options{})
+	assert.EqualError(t, err, "Cannot parse Imaginary Numbers")
+}
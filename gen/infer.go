@@ -0,0 +1,772 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// InferFiles aligns structurally similar source files and generates a single
+// generator for all of them. Identifiers and literals that differ between the
+// files become fields of a Params struct, runs of similar nodes that differ in
+// length become loops and nodes only some of the files have become
+// conditionals. The generator also holds the Params of every example.
+func InferFiles(srcs [][]byte, packName string, main bool) (*jen.File, error) {
+	return InferFilesWith(srcs, packName, main, Options{})
+}
+
+// InferFilesWith is InferFiles with options, of which only Report is used.
+// The positions of warnings are in the first example. Code the converter does
+// not support is an error rather than a panic.
+func InferFilesWith(srcs [][]byte, packName string, main bool, opts Options) (file *jen.File, err error) {
+	defer recoverConversion(&err)
+	if len(srcs) < 2 {
+		return nil, errors.New("at least two example files are needed")
+	}
+	var files []ast.Node
+	for i, s := range srcs {
+		f, err := parser.ParseFile(token.NewFileSet(), "", s, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("example %d: %v", i+1, err)
+		}
+		files = append(files, f)
+	}
+	a := &aligner{}
+	if !a.match(files) {
+		return nil, errors.New("example files could not be aligned")
+	}
+	base := files[0].(*ast.File)
+	mergeImports(base, files[1:])
+	cv := newConverter()
+	cv.src, cv.report = srcs[0], opts.Report.file("")
+	cv.paths, _ = imports(base.Imports)
+	cv.tmpl = a.resolve(cv, len(files))
+	return cv.generateFile(base, packName, main), nil
+}
+
+// InferFileBytes is InferFiles rendered to bytes
+func InferFileBytes(srcs [][]byte, packName string, main bool, formating bool) ([]byte, error) {
+	return InferFileBytesWith(srcs, packName, main, formating, Options{})
+}
+
+// InferFileBytesWith is InferFilesWith rendered to bytes
+func InferFileBytesWith(srcs [][]byte, packName string, main bool, formating bool, opts Options) ([]byte, error) {
+	file, err := InferFilesWith(srcs, packName, main, opts)
+	if err != nil {
+		return nil, err
+	}
+	return renderFile(file, formating)
+}
+
+// mergeImports adds the imports of the other files to base so that nodes of
+// every example can be qualified
+func mergeImports(base *ast.File, others []ast.Node) {
+	seen := map[string]bool{}
+	key := func(i *ast.ImportSpec) string {
+		if i.Name != nil {
+			return i.Name.String() + " " + i.Path.Value
+		}
+		return i.Path.Value
+	}
+	for _, i := range base.Imports {
+		seen[key(i)] = true
+	}
+	for _, o := range others {
+		for _, i := range o.(*ast.File).Imports {
+			if !seen[key(i)] {
+				seen[key(i)] = true
+				base.Imports = append(base.Imports, i)
+			}
+		}
+	}
+}
+
+// aligner matches the nodes of several instances against each other and
+// records where they differ. An instance is an example file, or an element
+// of a loop. Absent instances are nil.
+type aligner struct {
+	recs []interface{}
+}
+
+type useKind int
+
+const (
+	identUse useKind = iota
+	stringUse
+	intUse
+	litUse
+	codeUse
+)
+
+// leafRec is an identifier or literal that differs between instances
+type leafRec struct {
+	node ast.Node
+	use  useKind
+	vals []string
+	set  []bool
+}
+
+// codeRec is a node that can not be aligned and is passed to the generator
+// as jennifer code
+type codeRec struct {
+	nodes []ast.Node
+}
+
+// planRec is a list whose elements differ between instances
+type planRec struct {
+	parent  ast.Node
+	entries []planEntry
+}
+
+type planEntry struct {
+	node ast.Node
+	cond *leafRec
+	loop *loopRec
+}
+
+// loopRec is a run of similar nodes. The leaves of the template that differ
+// between the elements are fields of the loop items.
+type loopRec struct {
+	container string
+	tmpl      ast.Node
+	owner     []int
+	set       []bool
+	leaves    []*leafRec
+}
+
+var (
+	nodeType     = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	posType      = reflect.TypeOf(token.NoPos)
+	commentType  = reflect.TypeOf((*ast.CommentGroup)(nil))
+	commentsType = reflect.TypeOf([]*ast.CommentGroup{})
+	objectType   = reflect.TypeOf((*ast.Object)(nil))
+	scopeType    = reflect.TypeOf((*ast.Scope)(nil))
+	importsType  = reflect.TypeOf([]*ast.ImportSpec{})
+)
+
+// nodeFields calls fn for every field of n that takes part in the conversion
+func nodeFields(n ast.Node, fn func(f reflect.StructField, v reflect.Value)) {
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch f.Type {
+		case posType, commentType, commentsType, objectType, scopeType, importsType:
+			continue
+		}
+		// struct tags and unresolved identifiers are not converted
+		if f.Name == "Unresolved" || (f.Name == "Tag" && t == reflect.TypeOf(ast.Field{})) {
+			continue
+		}
+		fn(f, v.Field(i))
+	}
+}
+
+func isNodeList(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Implements(nodeType)
+}
+
+func valueNode(v reflect.Value) ast.Node {
+	if v.IsNil() {
+		return nil
+	}
+	return v.Interface().(ast.Node)
+}
+
+func valueNodes(v reflect.Value) []ast.Node {
+	var ret []ast.Node
+	for i := 0; i < v.Len(); i++ {
+		ret = append(ret, valueNode(v.Index(i)))
+	}
+	return ret
+}
+
+// shape is a key of n that ignores the names of identifiers and the values of
+// literals
+func shape(n ast.Node) string {
+	return maskedShape(n, nil)
+}
+
+// maskedShape is shape with the nodes mask is true for written as a leaf
+func maskedShape(n ast.Node, mask func(ast.Node) bool) string {
+	b := &strings.Builder{}
+	writeShape(b, n, mask)
+	return b.String()
+}
+
+func writeShape(b *strings.Builder, n ast.Node, mask func(ast.Node) bool) {
+	if n == nil {
+		b.WriteString("nil")
+		return
+	}
+	if mask != nil && mask(n) {
+		b.WriteString("mask")
+		return
+	}
+	switch t := n.(type) {
+	case *ast.Ident:
+		b.WriteString("id")
+		return
+	case *ast.BasicLit:
+		b.WriteString("lit:" + t.Kind.String())
+		return
+	}
+	b.WriteString(reflect.TypeOf(n).String() + "{")
+	nodeFields(n, func(f reflect.StructField, v reflect.Value) {
+		switch {
+		case f.Type.Implements(nodeType):
+			writeShape(b, valueNode(v), mask)
+		case isNodeList(f.Type):
+			b.WriteString("[")
+			for _, c := range valueNodes(v) {
+				writeShape(b, c, mask)
+				b.WriteString(",")
+			}
+			b.WriteString("]")
+		default:
+			fmt.Fprintf(b, "%v", v.Interface())
+		}
+		b.WriteString(";")
+	})
+	b.WriteString("}")
+}
+
+// leaves returns the identifiers and literals of n in a fixed order, nodes of
+// the same shape have their leaves at the same indexes
+func leaves(n ast.Node) []ast.Node {
+	return maskedLeaves(n, nil)
+}
+
+// maskedLeaves is leaves with the nodes mask is true for returned as a leaf
+func maskedLeaves(n ast.Node, mask func(ast.Node) bool) []ast.Node {
+	if n == nil {
+		return nil
+	}
+	if mask != nil && mask(n) {
+		return []ast.Node{n}
+	}
+	switch n.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return []ast.Node{n}
+	}
+	var ret []ast.Node
+	nodeFields(n, func(f reflect.StructField, v reflect.Value) {
+		switch {
+		case f.Type.Implements(nodeType):
+			ret = append(ret, maskedLeaves(valueNode(v), mask)...)
+		case isNodeList(f.Type):
+			for _, c := range valueNodes(v) {
+				ret = append(ret, maskedLeaves(c, mask)...)
+			}
+		}
+	})
+	return ret
+}
+
+func first(ns []ast.Node) ast.Node {
+	for _, n := range ns {
+		if n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// plannable reports whether the list field of parent can be generated with
+// a planned group
+func plannable(parent ast.Node, field string) bool {
+	switch parent.(type) {
+	case *ast.File:
+		return field == "Decls"
+	case *ast.BlockStmt, *ast.FieldList:
+		return field == "List"
+	case *ast.CaseClause, *ast.CommClause:
+		return field == "Body"
+	case *ast.CompositeLit:
+		return field == "Elts"
+	}
+	return false
+}
+
+// node aligns ns and falls back to passing the nodes as code when their
+// structure differs
+func (a *aligner) node(ns []ast.Node) bool {
+	mark := len(a.recs)
+	if a.match(ns) {
+		return true
+	}
+	a.recs = a.recs[:mark]
+	switch first(ns).(type) {
+	case ast.Expr, ast.Stmt, *ast.FuncDecl, *ast.GenDecl:
+		a.recs = append(a.recs, &codeRec{nodes: ns})
+		return true
+	}
+	return false
+}
+
+func (a *aligner) match(ns []ast.Node) bool {
+	rep := first(ns)
+	if rep == nil {
+		return true
+	}
+	for _, n := range ns {
+		if n != nil && reflect.TypeOf(n) != reflect.TypeOf(rep) {
+			return false
+		}
+	}
+	switch rep.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return a.leaf(ns)
+	}
+	ok := true
+	nodeFields(rep, func(f reflect.StructField, _ reflect.Value) {
+		if !ok {
+			return
+		}
+		vals := make([]reflect.Value, len(ns))
+		for i, n := range ns {
+			if n != nil {
+				vals[i] = reflect.ValueOf(n).Elem().FieldByIndex(f.Index)
+			}
+		}
+		switch {
+		case f.Type.Implements(nodeType):
+			children := make([]ast.Node, len(ns))
+			set := 0
+			for i, v := range vals {
+				if v.IsValid() {
+					children[i] = valueNode(v)
+					if children[i] != nil {
+						set++
+					}
+				}
+			}
+			if set != 0 && set != count(ns) {
+				ok = false
+				return
+			}
+			ok = a.node(children)
+		case isNodeList(f.Type):
+			lists := make([][]ast.Node, len(ns))
+			for i, v := range vals {
+				if v.IsValid() {
+					lists[i] = valueNodes(v)
+				}
+			}
+			if plannable(rep, f.Name) {
+				a.list(rep, ns, lists)
+				return
+			}
+			ok = a.elements(ns, lists)
+		default:
+			for _, v := range vals {
+				if v.IsValid() && !reflect.DeepEqual(v.Interface(), vals[index(ns, rep)].Interface()) {
+					ok = false
+					return
+				}
+			}
+		}
+	})
+	return ok
+}
+
+func count(ns []ast.Node) int {
+	c := 0
+	for _, n := range ns {
+		if n != nil {
+			c++
+		}
+	}
+	return c
+}
+
+func index(ns []ast.Node, n ast.Node) int {
+	for i, o := range ns {
+		if o == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// elements aligns lists of the same length element by element
+func (a *aligner) elements(ns []ast.Node, lists [][]ast.Node) bool {
+	l := len(lists[index(ns, first(ns))])
+	for i, n := range ns {
+		if n != nil && len(lists[i]) != l {
+			return false
+		}
+	}
+	for j := 0; j < l; j++ {
+		col := make([]ast.Node, len(ns))
+		for i, n := range ns {
+			if n != nil {
+				col[i] = lists[i][j]
+			}
+		}
+		if !a.node(col) {
+			return false
+		}
+	}
+	return true
+}
+
+// leafValue returns the string a leaf is compared by and how it is used in
+// the generator
+func leafValue(n ast.Node) (string, useKind) {
+	switch t := n.(type) {
+	case *ast.Ident:
+		return t.Name, identUse
+	case *ast.BasicLit:
+		switch t.Kind {
+		case token.INT:
+			if _, err := strconv.ParseInt(t.Value, 10, 32); err == nil {
+				return t.Value, intUse
+			}
+		case token.STRING:
+			if s, err := strconv.Unquote(t.Value); err == nil {
+				return s, stringUse
+			}
+		}
+		return t.Value, litUse
+	}
+	return "", codeUse
+}
+
+// leafRecord records the leaves ns when their values differ. Literals of a
+// different kind can not be aligned.
+func leafRecord(ns []ast.Node) (*leafRec, bool) {
+	rep := first(ns)
+	r := &leafRec{node: rep, vals: make([]string, len(ns)), set: make([]bool, len(ns))}
+	uses := map[useKind]bool{}
+	var kind token.Token
+	for i, n := range ns {
+		if n == nil {
+			continue
+		}
+		if l, ok := n.(*ast.BasicLit); ok {
+			if kind != token.ILLEGAL && l.Kind != kind {
+				return nil, false
+			}
+			kind = l.Kind
+		}
+		r.vals[i], r.use = leafValue(n)
+		r.set[i] = true
+		uses[r.use] = true
+	}
+	if len(uses) > 1 {
+		// literals that are written differently fall back to their source
+		r.use = litUse
+		for i, n := range ns {
+			if n != nil {
+				r.vals[i] = n.(*ast.BasicLit).Value
+			}
+		}
+	}
+	for i := range ns {
+		if r.set[i] && r.vals[i] != r.vals[index(ns, rep)] {
+			return r, true
+		}
+	}
+	return nil, true
+}
+
+func (a *aligner) leaf(ns []ast.Node) bool {
+	r, ok := leafRecord(ns)
+	if r != nil {
+		a.recs = append(a.recs, r)
+	}
+	return ok
+}
+
+// column is a run of elements in the lists of the instances that are
+// aligned with each other
+type column struct {
+	shape   string
+	shallow string
+	// single is set while every run has one element and uniform while every
+	// run has the same shape
+	single  bool
+	uniform bool
+	runs    [][]ast.Node
+}
+
+// elemRun is a run of consecutive elements of the same shape
+type elemRun struct {
+	shape   string
+	shallow string
+	nodes   []ast.Node
+}
+
+func splitRuns(l []ast.Node) []elemRun {
+	var runs []elemRun
+	for _, n := range l {
+		s := shape(n)
+		if len(runs) > 0 && runs[len(runs)-1].shape == s {
+			runs[len(runs)-1].nodes = append(runs[len(runs)-1].nodes, n)
+			continue
+		}
+		runs = append(runs, elemRun{shape: s, shallow: shallow(n), nodes: []ast.Node{n}})
+	}
+	return runs
+}
+
+// shallow is a key of n that only looks at its type and tokens, nodes with
+// the same key can be aligned even though their children differ
+func shallow(n ast.Node) string {
+	b := &strings.Builder{}
+	b.WriteString(reflect.TypeOf(n).String())
+	nodeFields(n, func(f reflect.StructField, v reflect.Value) {
+		switch {
+		case f.Type.Implements(nodeType):
+			fmt.Fprintf(b, ";%v", v.IsNil())
+		case !isNodeList(f.Type):
+			fmt.Fprintf(b, ";%v", v.Interface())
+		}
+	})
+	return b.String()
+}
+
+// score is how well a run matches a column. Runs of the same shape can be
+// looped over, single elements of the same kind can be aligned.
+func (c *column) score(r elemRun) int {
+	switch {
+	case c.uniform && c.shape == r.shape:
+		return 2
+	case c.shallow == r.shallow && c.single && len(r.nodes) == 1:
+		return 1
+	}
+	return 0
+}
+
+// columns aligns the runs of every present list with the columns found so
+// far, maximizing the score of the matched runs
+func columns(ns []ast.Node, lists [][]ast.Node) []*column {
+	var cols []*column
+	for i, n := range ns {
+		if n == nil {
+			continue
+		}
+		runs := splitRuns(lists[i])
+		// best[x][y] is the best score of aligning cols[x:] and runs[y:]
+		best := make([][]int, len(cols)+1)
+		for x := range best {
+			best[x] = make([]int, len(runs)+1)
+		}
+		for x := len(cols) - 1; x >= 0; x-- {
+			for y := len(runs) - 1; y >= 0; y-- {
+				best[x][y] = maxInt(best[x+1][y], best[x][y+1])
+				if s := cols[x].score(runs[y]); s > 0 {
+					best[x][y] = maxInt(best[x][y], best[x+1][y+1]+s)
+				}
+			}
+		}
+		var merged []*column
+		x, y := 0, 0
+		for x < len(cols) || y < len(runs) {
+			switch {
+			case x < len(cols) && y < len(runs) && cols[x].score(runs[y]) > 0 &&
+				best[x][y] == best[x+1][y+1]+cols[x].score(runs[y]):
+				cols[x].runs[i] = runs[y].nodes
+				cols[x].single = cols[x].single && len(runs[y].nodes) == 1
+				cols[x].uniform = cols[x].uniform && cols[x].shape == runs[y].shape
+				merged = append(merged, cols[x])
+				x++
+				y++
+			case y == len(runs) || (x < len(cols) && best[x][y] == best[x+1][y]):
+				merged = append(merged, cols[x])
+				x++
+			default:
+				c := &column{
+					shape:   runs[y].shape,
+					shallow: runs[y].shallow,
+					single:  len(runs[y].nodes) == 1,
+					uniform: true,
+					runs:    make([][]ast.Node, len(ns)),
+				}
+				c.runs[i] = runs[y].nodes
+				merged = append(merged, c)
+				y++
+			}
+		}
+		cols = merged
+	}
+	return cols
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// list aligns the elements of a plannable list. Columns every instance has
+// the same number of are aligned element by element, a single element only
+// some instances have becomes a conditional and everything else a loop.
+func (a *aligner) list(parent ast.Node, ns []ast.Node, lists [][]ast.Node) {
+	var entries []planEntry
+	planned := false
+	for _, c := range columns(ns, lists) {
+		min, max := -1, 0
+		for i, n := range ns {
+			if n == nil {
+				continue
+			}
+			l := len(c.runs[i])
+			if min == -1 || l < min {
+				min = l
+			}
+			if l > max {
+				max = l
+			}
+		}
+		switch {
+		case min == max:
+			for j := 0; j < max; j++ {
+				col := make([]ast.Node, len(ns))
+				for i, n := range ns {
+					if n != nil {
+						col[i] = c.runs[i][j]
+					}
+				}
+				a.node(col)
+				entries = append(entries, planEntry{node: first(col)})
+			}
+		case max == 1:
+			col := make([]ast.Node, len(ns))
+			cond := &leafRec{vals: make([]string, len(ns)), set: make([]bool, len(ns))}
+			for i, n := range ns {
+				if n != nil {
+					cond.vals[i], cond.set[i] = strconv.FormatBool(len(c.runs[i]) == 1), true
+					if len(c.runs[i]) == 1 {
+						col[i] = c.runs[i][0]
+					}
+				}
+			}
+			a.node(col)
+			cond.node = first(col)
+			entries = append(entries, planEntry{node: first(col), cond: cond})
+			planned = true
+		default:
+			l := a.loop(parent, ns, c.runs)
+			entries = append(entries, planEntry{node: l.tmpl, loop: l})
+			planned = true
+		}
+	}
+	if planned {
+		a.recs = append(a.recs, &planRec{parent: parent, entries: entries})
+	}
+}
+
+// loop records a run of elements of the same shape. Leaves that are the same
+// within each instance are parameters of the instance, the others are fields
+// of the loop items.
+func (a *aligner) loop(parent ast.Node, ns []ast.Node, runs [][]ast.Node) *loopRec {
+	l := &loopRec{container: containerName(parent), set: make([]bool, len(ns))}
+	var items [][]ast.Node
+	for i, run := range runs {
+		for _, n := range run {
+			if l.tmpl == nil {
+				l.tmpl = n
+			}
+			l.owner = append(l.owner, i)
+			items = append(items, leaves(n))
+		}
+		l.set[i] = len(run) > 0
+	}
+	for j, tl := range items[0] {
+		col := make([]ast.Node, len(items))
+		for k := range items {
+			col[k] = items[k][j]
+		}
+		r, ok := leafRecord(col)
+		if !ok || r == nil {
+			continue
+		}
+		r.node = tl
+		if inst, ok := perInstance(r, l.owner, len(ns)); ok {
+			a.recs = append(a.recs, inst)
+			continue
+		}
+		l.leaves = append(l.leaves, r)
+	}
+	return l
+}
+
+// perInstance returns the leaf as a parameter of the instances when it has a
+// single value within each of them
+func perInstance(r *leafRec, owner []int, n int) (*leafRec, bool) {
+	inst := &leafRec{node: r.node, use: r.use, vals: make([]string, n), set: make([]bool, n)}
+	for k, i := range owner {
+		if inst.set[i] && inst.vals[i] != r.vals[k] {
+			return nil, false
+		}
+		inst.vals[i], inst.set[i] = r.vals[k], true
+	}
+	return inst, true
+}
+
+func containerName(parent ast.Node) string {
+	switch parent.(type) {
+	case *ast.File:
+		return "Decls"
+	case *ast.FieldList:
+		return "Fields"
+	case *ast.CompositeLit:
+		return "Elts"
+	case *ast.GenDecl:
+		return "Specs"
+	}
+	return "Stmts"
+}
+
+// describe returns a name for a parameter related to n
+func describe(n ast.Node) string {
+	switch t := n.(type) {
+	case *ast.FuncDecl:
+		return exported(t.Name.String())
+	}
+	for _, l := range leaves(n) {
+		if id, ok := l.(*ast.Ident); ok {
+			return exported(id.Name)
+		}
+	}
+	return strings.TrimPrefix(reflect.TypeOf(n).String(), "*ast.")
+}
+
+// exported turns s into an exported identifier
+func exported(s string) string {
+	b := &strings.Builder{}
+	up := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			up = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteString("V")
+		}
+		if up {
+			r = unicode.ToUpper(r)
+			up = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,198 @@
+package gen
+
+import (
+	"bytes"
+	"go/format"
+	"testing"
+
+	"github.com/aloder/tojen/run"
+	"github.com/dave/jennifer/jen"
+	"github.com/stretchr/testify/assert"
+)
+
+type tci struct {
+	Name  string
+	Codes []string
+}
+
+var inferTests = []tci{
+	tci{
+		"identifiers and literals",
+		[]string{`package main
+
+func main() {
+	println("Hello", 1)
+}
+`, `package main
+
+func main() {
+	println("World", 2)
+}
+`},
+	},
+	tci{
+		"derived identifiers",
+		[]string{`package model
+
+type User struct{}
+
+func NewUser() *User {
+	return &User{}
+}
+func (u *User) TableName() string {
+	return "users"
+}
+`, `package model
+
+type Order struct{}
+
+func NewOrder() *Order {
+	return &Order{}
+}
+func (o *Order) TableName() string {
+	return "orders"
+}
+`},
+	},
+	tci{
+		"loops and conditionals",
+		[]string{`package model
+
+import "fmt"
+
+type User struct {
+	Name  string
+	Email string
+}
+
+func (u *User) Validate() error {
+	if u.Name == "" {
+		return fmt.Errorf("missing Name")
+	}
+	if u.Email == "" {
+		return fmt.Errorf("missing Email")
+	}
+	return nil
+}
+`, `package model
+
+import "fmt"
+
+type Order struct {
+	Total int
+}
+
+func (o *Order) Validate() error {
+	if o.Total == 0 {
+		return fmt.Errorf("missing Total")
+	}
+	return nil
+}
+func (o *Order) Cancel() {
+	o.Total = 0
+}
+`, `package model
+
+import "fmt"
+
+type Product struct {
+	Title string
+	Price float64
+	Stock int
+}
+
+func (p *Product) Validate() error {
+	if p.Title == "" {
+		return fmt.Errorf("missing Title")
+	}
+	if p.Price == 0 {
+		return fmt.Errorf("missing Price")
+	}
+	if p.Stock == 0 {
+		return fmt.Errorf("missing Stock")
+	}
+	return nil
+}
+`},
+	},
+}
+
+func TestInferFiles(t *testing.T) {
+	for _, tc := range inferTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			var srcs [][]byte
+			want := ""
+			for _, code := range test.Codes {
+				fmtBytes, err := format.Source([]byte(code))
+				if err != nil {
+					assert.Nil(t, err, "Formating error on: "+test.Name)
+					return
+				}
+				srcs = append(srcs, []byte(code))
+				want += string(fmtBytes) + "\n"
+			}
+			file, err := InferFiles(srcs, "main", true)
+			if err != nil {
+				assert.Nil(t, err, "Could not infer generator")
+				return
+			}
+			runGenerator(t, file, want)
+		})
+	}
+}
+
+func TestInferFilesNeedsExamples(t *testing.T) {
+	_, err := InferFiles([][]byte{[]byte("package main")}, "main", false)
+	assert.NotNil(t, err)
+}
+
+func TestInferFilesErrors(t *testing.T) {
+	a := "package main\n\nvar a = 1\n"
+	_, err := InferFiles([][]byte{[]byte(a), []byte("package main\n\nvar a =\n")}, "main", false)
+	assert.EqualError(t, err, "example 2: 3:9: expected operand, found 'EOF'")
+
+	a = "package main\n\nvar a = 1\n\nvar c = 1i\n"
+	b := "package main\n\nvar a = 2\n\nvar c = 1i\n"
+	_, err = InferFiles([][]byte{[]byte(a), []byte(b)}, "main", false)
+	assert.EqualError(t, err, "Cannot parse Imaginary Numbers")
+}
+
+func TestInferFilesWarnings(t *testing.T) {
+	a := "package main\n\n// main prints\nfunc main() {\n\tprintln(\"a\")\n}\n"
+	b := "package main\n\n// main prints\nfunc main() {\n\tprintln(\"b\")\n}\n"
+	r := &Report{}
+	_, err := InferFilesWith([][]byte{[]byte(a), []byte(b)}, "main", false, Options{Report: r})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3:1: comment: comment is discarded"}, r.Warnings())
+}
+
+// roundTrip converts code with the options, runs the generator and checks
+// that it renders the code. It returns the generator.
+func roundTrip(t *testing.T, code string, opts Options) string {
+	t.Helper()
+	want, err := format.Source([]byte(code))
+	if err != nil {
+		assert.Nil(t, err, "Formating error")
+		return ""
+	}
+	return runGenerator(t, GenerateFileWith([]byte(code), "main", true, opts), string(want))
+}
+
+// runGenerator renders file, runs it and checks that it prints want. It
+// returns the generator.
+func runGenerator(t *testing.T, file *jen.File, want string) string {
+	t.Helper()
+	b := &bytes.Buffer{}
+	if err := file.Render(b); err != nil {
+		assert.Nil(t, err, "Could not render test file")
+		return ""
+	}
+	ret, err := run.Exec(b.String())
+	if err != nil {
+		assert.Nil(t, err, "Could not execute rendered test file: \n"+b.String())
+		return b.String()
+	}
+	assert.Equal(t, want, *ret, "Gen Code: \n"+b.String())
+	return b.String()
+}
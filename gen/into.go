@@ -0,0 +1,208 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// GenerateInto converts the source and inserts the generator functions into
+// target, an existing generator, at the line of the first comment containing
+// marker. The functions are renamed where their names are taken in target,
+// declarations of templates target already has are left out and the imports
+// are merged. Code the converter does not support is an error rather than a
+// panic.
+func GenerateInto(target []byte, marker string, src []byte, formating bool, opts Options) (out []byte, err error) {
+	defer recoverConversion(&err)
+	fset := token.NewFileSet()
+	t, err := parser.ParseFile(fset, "", target, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	at := -1
+	for _, c := range t.Comments {
+		if strings.Contains(c.Text(), marker) {
+			at = fset.Position(c.Pos()).Offset
+			break
+		}
+	}
+	if at == -1 {
+		return nil, fmt.Errorf("no comment with %q in the target", marker)
+	}
+	// insert before the line of the marker, so that insertions follow each
+	// other
+	for at > 0 && target[at-1] != '\n' {
+		at--
+	}
+
+	used := map[string]bool{}
+	for _, d := range t.Decls {
+		for _, name := range declNames(d) {
+			used[name] = true
+		}
+	}
+	// the generated code refers to jennifer as jen, it is renamed after
+	jenName := "jen"
+	file := jen.NewFile(t.Name.Name)
+	for _, imp := range t.Imports {
+		if imp.Name == nil || imp.Name.Name == "_" {
+			continue
+		}
+		path, _ := strconv.Unquote(imp.Path.Value)
+		switch {
+		case path == jenImp:
+			jenName = imp.Name.Name
+		case imp.Name.Name == ".":
+			return nil, fmt.Errorf("the target imports %s with ., which is not supported", path)
+		default:
+			file.ImportAlias(path, imp.Name.Name)
+		}
+	}
+
+	cv := newConverter()
+	cv.src, cv.report = src, opts.Report.file("")
+	astFile := cv.parse(opts.Partial)
+	selectDecls(astFile, opts)
+	cv.tmpl = cv.fileTemplate(astFile, opts, "")
+	for _, c := range cv.fileCode(astFile, uniqueName("genFile", used), used) {
+		file.Add(c)
+	}
+	if cv.tmpl != nil {
+		for _, d := range cv.tmpl.decls {
+			if !used[codeName(d)] {
+				file.Add(d)
+			}
+		}
+	}
+	gen, err := renderFile(file, formating)
+	if err != nil {
+		return nil, err
+	}
+
+	gset := token.NewFileSet()
+	g, err := parser.ParseFile(gset, "", gen, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	have := map[string]bool{}
+	for _, imp := range t.Imports {
+		have[imp.Path.Value] = true
+	}
+	var imports []string
+	for _, imp := range g.Imports {
+		if !have[imp.Path.Value] {
+			start, end := gset.Position(imp.Pos()).Offset, gset.Position(imp.End()).Offset
+			imports = append(imports, string(gen[start:end]))
+		}
+	}
+	decls := ""
+	if len(g.Decls) > 0 {
+		start := len(gen)
+		for _, d := range g.Decls {
+			if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				continue
+			}
+			start = gset.Position(d.Pos()).Offset
+			break
+		}
+		decls = strings.TrimRight(renameJen(gset, g, gen, start, jenName), "\n") + "\n\n"
+	}
+
+	// the imports go after the last import declaration
+	end := fset.Position(t.Name.End()).Offset
+	for _, d := range t.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			end = fset.Position(d.End()).Offset
+		}
+	}
+	if at < end {
+		return nil, fmt.Errorf("the comment with %q comes before the imports of the target", marker)
+	}
+	ret := string(target[:end])
+	switch len(imports) {
+	case 0:
+	case 1:
+		ret += "\n\nimport " + imports[0]
+	default:
+		ret += "\n\nimport (\n\t" + strings.Join(imports, "\n\t") + "\n)"
+	}
+	ret += string(target[end:at]) + decls + string(target[at:])
+	b, err := format.Source([]byte(ret))
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// renameJen returns the code of f from the offset start with the selectors
+// of jennifer using name, no selector for a dot import
+func renameJen(fset *token.FileSet, f *ast.File, code []byte, start int, name string) string {
+	var offsets []int
+	ast.Inspect(f, func(n ast.Node) bool {
+		if s, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := s.X.(*ast.Ident); ok && id.Name == "jen" {
+				offsets = append(offsets, fset.Position(id.Pos()).Offset)
+			}
+		}
+		return true
+	})
+	b := &strings.Builder{}
+	last := start
+	for _, off := range offsets {
+		if off < start {
+			continue
+		}
+		b.Write(code[last:off])
+		if name == "." {
+			// drop jen and the dot
+			last = off + len("jen.")
+		} else {
+			b.WriteString(name)
+			last = off + len("jen")
+		}
+	}
+	b.Write(code[last:])
+	return b.String()
+}
+
+// declNames returns the names a declaration declares in its package
+func declNames(d ast.Decl) []string {
+	switch t := d.(type) {
+	case *ast.FuncDecl:
+		if t.Recv == nil {
+			return []string{t.Name.Name}
+		}
+	case *ast.GenDecl:
+		var ret []string
+		for _, spec := range t.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				ret = append(ret, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					ret = append(ret, n.Name)
+				}
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+// codeName returns the first name declared by generated code
+func codeName(c jen.Code) string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+fmt.Sprintf("%#v", c), 0)
+	if err != nil || len(f.Decls) == 0 {
+		return ""
+	}
+	if names := declNames(f.Decls[0]); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package gen
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateInto(t *testing.T) {
+	target := `package main
+
+import "fmt"
+
+func genFile() string {
+	return "package x\n"
+}
+
+// tojen:insert
+
+func main() {
+	fmt.Printf("%#v%s", genFile2(), genFile())
+}
+`
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n"
+	b, err := GenerateInto([]byte(target), "tojen:insert", []byte(src), false, Options{})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	code := string(b)
+	assert.Contains(t, code, "import jen \"github.com/dave/jennifer/jen\"\n")
+	assert.Contains(t, code, "func genFile2() *jen.File {")
+	out, err := Render(b)
+	if err != nil {
+		assert.Nil(t, err, code)
+		return
+	}
+	want, _ := format.Source([]byte(src))
+	assert.Equal(t, string(want)+"package x\n", string(out))
+
+	// a second insertion goes after the first with its own names
+	b, err = GenerateInto(b, "tojen:insert", []byte(src), false, Options{})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	assert.Contains(t, string(b), "func genFuncmain2() jen.Code {")
+	assert.Contains(t, string(b), "func genFile3() *jen.File {")
+
+	_, err = GenerateInto([]byte(target), "tojen:missing", []byte(src), false, Options{})
+	assert.NotNil(t, err)
+}
+
+func TestGenerateIntoAlias(t *testing.T) {
+	target := `package main
+
+import j "github.com/dave/jennifer/jen"
+
+func genFile() *j.File {
+	return j.NewFile("x")
+}
+
+// tojen:insert
+`
+	src := "package main\n\ntype User struct {\n\tName string\n}\n"
+	b, err := GenerateInto([]byte(target), "tojen:insert", []byte(src), false, Options{Fields: []string{"User"}})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	code := string(b)
+	assert.NotContains(t, code, "jen.")
+	assert.Contains(t, code, "func genStructUser(name string, fields []Field) j.Code {")
+	assert.Contains(t, code, "func genFile2() *j.File {")
+
+	// the declarations of the template are only added once
+	b, err = GenerateInto(b, "tojen:insert", []byte(src), false, Options{Fields: []string{"User"}})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	_, err = Render(b)
+	assert.Nil(t, err, string(b))
+}
+
+func TestGenerateIntoUnsupported(t *testing.T) {
+	target := "package main\n\n// tojen:insert\n"
+	_, err := GenerateInto([]byte(target), "tojen:insert", []byte("package main\n\nvar c = 1i\n"), false, Options{})
+	assert.EqualError(t, err, "Cannot parse Imaginary Numbers")
+	_, err = GenerateInto([]byte(target), "tojen:insert", []byte("package main\n\nvar c =\n"), false, Options{})
+	assert.EqualError(t, err, "3:9: expected operand, found 'EOF'")
+}
@@ -0,0 +1,48 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// recoverVerbatim is deferred by the conversions of nodes in lenient mode. It
+// turns a panic of the converter on n into a warning and sets ret to the
+// verbatim source of n. full is set for nodes whose code starts with jen
+// rather than with the selector following it.
+func (cv *converter) recoverVerbatim(n ast.Node, ret *jen.Code, full bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	cv.warn(n, "verbatim", "%s, the source is kept verbatim", panicMessage(r))
+	*ret = verbatim(n, full)
+}
+
+// verbatim returns code generating n as its source rendered by go/printer
+func verbatim(n ast.Node, full bool) jen.Code {
+	b := &bytes.Buffer{}
+	if err := printer.Fprint(b, token.NewFileSet(), n); err != nil {
+		panic(err)
+	}
+	code := jen.Dot("Id").Call(jen.Lit(b.String()))
+	if full {
+		return jen.Id("jen").Add(code)
+	}
+	return code
+}
+
+// panicMessage returns the message of a panic of the converter without the
+// position it holds, which is not readable
+func panicMessage(r interface{}) string {
+	msg := fmt.Sprint(r)
+	if i := strings.Index(msg, " at "); i != -1 {
+		msg = msg[:i]
+	}
+	return msg
+}
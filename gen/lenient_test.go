@@ -0,0 +1,58 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/aloder/tojen/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLenient(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+func Keys[K comparable, V any](ps []Pair[K, V]) []K {
+	return nil
+}
+
+func main() {
+	p := Pair[string, int]{Key: "a", Val: 0x10}
+	c := 2i
+	fmt.Println(p, c, Keys([]Pair[string, int]{p}))
+}
+`
+	assert.Panics(t, func() { GenerateFile([]byte(src), "main", true) })
+
+	r := &Report{}
+	out, err := GenerateFileBytesWith([]byte(src), "main", true, false, Options{Lenient: true, Report: r})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	ret, err := run.Exec(string(out))
+	if err != nil {
+		assert.Nil(t, err, "Could not execute rendered test file: \n"+string(out))
+		return
+	}
+	// the blank lines between declarations may differ
+	assert.Nil(t, Compare("want.go", []byte(src), "got.go", []byte(*ret)))
+
+	var warnings []string
+	for _, w := range r.Files[0].Warnings {
+		warnings = append(warnings, w.String())
+	}
+	assert.Equal(t, []string{
+		"5:10: generics: type parameters are not supported, the source is kept verbatim",
+		"10:10: generics: type parameters are not supported, the source is kept verbatim",
+		"15:7: verbatim: Not Handled gen expr: *ast.IndexListExpr, the source is kept verbatim",
+		"15:40: literal: integer literal 0x10 is kept verbatim",
+		"16:7: verbatim: Cannot parse Imaginary Numbers, the source is kept verbatim",
+		"17:27: verbatim: Not Handled gen expr: *ast.IndexListExpr, the source is kept verbatim",
+	}, warnings)
+}
@@ -13,28 +13,152 @@ import (
 
 var jenImp = "github.com/dave/jennifer/jen"
 
-func funcDecl(s *ast.FuncDecl) jen.Code {
+func (cv *converter) funcDecl(s *ast.FuncDecl) (code jen.Code) {
+	if cv.lenient {
+		defer cv.recoverVerbatim(s, &code, true)
+	}
+	if c, ok := cv.subst(s); ok {
+		return c
+	}
+	if cv.lenient && s.Type.TypeParams != nil {
+		cv.warn(s.Type.TypeParams, "generics", "type parameters are not supported, the source is kept verbatim")
+		return verbatim(s, true)
+	}
 	ret := jen.Qual("github.com/dave/jennifer/jen", "Func").Call()
 	if s.Recv != nil {
-		ret.Dot("Params").Call(fieldList(s.Recv)...)
+		ret.Add(cv.fieldList(s.Recv, "Params"))
+	}
+	ret.Add(cv.ident(s.Name))
+	ret.Add(cv.funcType(s.Type))
+	// a function implemented in assembly has no body
+	if s.Body != nil {
+		ret.Add(cv.blockStmt(s.Body))
 	}
-	ret.Add(ident(s.Name))
-	ret.Add(funcType(s.Type))
-	ret.Add(blockStmt(s.Body))
 	return ret
 }
 
-var paths = map[string]string{}
 var formating = false
 
+// converter holds the state of converting a file, so that several files can
+// be converted at the same time
+type converter struct {
+	// paths maps the names of the imports of the file to their paths
+	paths map[string]string
+	// tmpl is set while a file is turned into a parameterized generator
+	// instead of a literal copy of the source. It is nil for plain
+	// conversions.
+	tmpl *template
+	// naming is the naming strategy of the generator functions of
+	// declarations, see Options.Naming
+	naming string
+	// src is the source of the file being converted, for the positions of
+	// warnings and reports
+	src []byte
+	// warnings are the constructs of the source dropped or approximated so
+	// far
+	warnings []Warning
+	// report is filled with the description of the file when set
+	report *FileReport
+	// lenient keeps the source of nodes the converter can not convert
+	// verbatim, see Options.Lenient
+	lenient bool
+}
+
+func newConverter() *converter {
+	return &converter{paths: map[string]string{}}
+}
+
+// Options changes how GenerateFileWith converts a file
+type Options struct {
+	// Fields lists struct types whose generators take the name of the type
+	// and a slice of Field instead of the fixed field list of the source
+	Fields []string
+	// Interfaces lists interfaces whose generators, and the generators of
+	// the methods implementing them, take a slice of Method instead of the
+	// fixed method set of the source. It can not be used with Fields or
+	// Enums.
+	Interfaces []string
+	// Enums lists types with a const block of members whose generators, and
+	// the generators mentioning the members, take a slice of Member. It can
+	// not be used with Fields or Interfaces.
+	Enums []string
+	// Factor generates runs of similar siblings, such as struct fields,
+	// switch cases or map entries that only differ in identifiers and
+	// literals, with a loop over a slice holding the differences
+	Factor bool
+	// Only lists the names of the declarations to generate, methods are
+	// named by their name or Type.Name. Every declaration is generated when
+	// it is empty.
+	Only []string
+	// Kinds lists the kinds of declarations to generate: const, var, type
+	// and func, which includes methods
+	Kinds []string
+	// Exclude lists the names of declarations to leave out, like Only
+	Exclude []string
+	// Naming is how the generator functions of declarations are named:
+	// position, the default, names them by their offset in the source like
+	// genDeclAt29, and name by the kind and name of the declaration like
+	// genTypeUser, which keeps them when the source moves
+	Naming string
+	// JenPath is the import path of jennifer in the generated code, for a
+	// fork or a vendored copy
+	JenPath string
+	// Report is filled with a description of the conversion when set
+	Report *Report
+	// Partial converts the top level declarations of files with syntax
+	// errors that parse, leaving out the others with a warning
+	Partial bool
+	// Lenient generates declarations, statements and expressions tojen can
+	// not convert from their source, rendered by go/printer, with a warning
+	// instead of failing. Integer literals it can not convert are kept as
+	// they are written instead of being dropped.
+	Lenient bool
+}
+
 // GenerateFileBytes takes an array of bytes and transforms it into jennifer
 // code
 func GenerateFileBytes(s []byte, packName string, main bool, formating bool) ([]byte, error) {
-	file := GenerateFile(s, packName, main)
+	return GenerateFileBytesWith(s, packName, main, formating, Options{})
+}
+
+// GenerateFileBytesWith is GenerateFileBytes with options. Code the converter
+// does not support is an error rather than a panic.
+func GenerateFileBytesWith(s []byte, packName string, main bool, formating bool, opts Options) (b []byte, err error) {
+	defer recoverConversion(&err)
+	return renderFileWith(GenerateFileWith(s, packName, main, opts), formating, opts)
+}
+
+// renderFileWith is renderFile importing jennifer from the path of the
+// options
+func renderFileWith(file *jen.File, formating bool, opts Options) ([]byte, error) {
+	b, err := renderFile(file, formating)
+	if err != nil || opts.JenPath == "" || opts.JenPath == jenImp {
+		return b, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", b, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range f.Imports {
+		if imp.Path.Value != strconv.Quote(jenImp) {
+			continue
+		}
+		start, end := fset.Position(imp.Path.Pos()).Offset, fset.Position(imp.Path.End()).Offset
+		path := strconv.Quote(opts.JenPath)
+		if imp.Name == nil {
+			path = "jen " + path
+		}
+		return append(append(append([]byte{}, b[:start]...), path...), b[end:]...), nil
+	}
+	return b, nil
+}
+
+func renderFile(file *jen.File, formating bool) ([]byte, error) {
 	b := &bytes.Buffer{}
 	err := file.Render(b)
 	if err != nil {
-		return s, err
+		return nil, err
 	}
 	ret := b.Bytes()
 	if formating {
@@ -80,50 +204,148 @@ func imports(imports []*ast.ImportSpec) (map[string]string, []jen.Code) {
 // GenerateFile Generates a jennifer file given a series of bytes a package name
 // and if you want a main function or not
 func GenerateFile(s []byte, packName string, main bool) *jen.File {
+	return GenerateFileWith(s, packName, main, Options{})
+}
+
+// GenerateFileWith is GenerateFile with options
+func GenerateFileWith(s []byte, packName string, main bool, opts Options) *jen.File {
+	cv := newConverter()
+	cv.src, cv.report = s, opts.Report.file("")
+	astFile := cv.parse(opts.Partial)
+	selectDecls(astFile, opts)
+	cv.tmpl = cv.fileTemplate(astFile, opts, "")
+	return cv.generateFile(astFile, packName, main)
+}
+
+// fileTemplate returns the template for the options or nil when the file is
+// converted as it is. prefix is put before the names of the variables holding
+// the data of the template.
+func (cv *converter) fileTemplate(astFile *ast.File, opts Options, prefix string) *template {
+	cv.paths, _ = imports(astFile.Imports)
+	cv.naming = opts.Naming
+	cv.lenient = opts.Lenient
+	var t *template
+	switch {
+	case len(opts.Fields) > 0:
+		t = cv.fieldsTemplate(astFile, opts.Fields)
+	case len(opts.Interfaces) > 0:
+		t = cv.methodsTemplate(astFile, opts.Interfaces)
+	case len(opts.Enums) > 0:
+		t = cv.enumsTemplate(astFile, opts.Enums)
+	}
+	if opts.Factor {
+		if t == nil {
+			t = newTemplate()
+		}
+		t.prefix = prefix
+		t.factor(cv, astFile)
+	}
+	return t
+}
+
+func (cv *converter) generateFile(astFile *ast.File, packName string, main bool) *jen.File {
 	file := jen.NewFile(packName)
-	astFile := parseFile(s)
+	for _, c := range cv.fileCode(astFile, "genFile", map[string]bool{}) {
+		file.Add(c)
+	}
+	if cv.tmpl != nil {
+		for _, d := range cv.tmpl.decls {
+			file.Add(d)
+		}
+	}
+	// if main then generate a main function that prints out the output of the
+	// patch function
+	if main {
+		file.Add(cv.genMainFunc())
+	}
+	return file
+}
+
+// fileCode returns the generator functions of the declarations of the file
+// and the function genName piecing them together. The names of the functions
+// are added to used.
+func (cv *converter) fileCode(astFile *ast.File, genName string, used map[string]bool) []jen.Code {
+	var ret []jen.Code
 	var anonImports []jen.Code
-	// paths is a global variable to map the exported object to the import
-	paths, anonImports = imports(astFile.Imports)
+	// paths maps the exported object to the import
+	cv.paths, anonImports = imports(astFile.Imports)
+	cv.warnFile(astFile)
+
+	var params, args []jen.Code
+	if cv.tmpl != nil {
+		params, args = cv.tmpl.params, cv.tmpl.args
+	}
 
 	// generate the generative code based on the file
-	decls := []string{}
-	for _, decl := range astFile.Decls {
-		code, name := makeJenCode(decl)
-		file.Add(code)
-		decls = append(decls, name)
+	decls := []jen.Code{}
+	for _, e := range cv.declEntries(astFile) {
+		name := e.name
+		if name == "" {
+			name = cv.declName(e.node.(ast.Decl))
+		}
+		name = uniqueName(name, used)
+		fparams, fargs := params, args
+		if e.params != nil {
+			fparams, fargs = e.params, e.args
+		}
+		if e.rng != nil {
+			fparams = append(append([]jen.Code{}, fparams...), jen.Id(e.v).Add(e.typ))
+			fargs = append(append([]jen.Code{}, fargs...), jen.Id(e.v))
+		}
+		cv.reportDecl(e.node.(ast.Decl), name)
+		ret = append(ret, cv.makeJenCode(e.node.(ast.Decl), name, fparams...))
+		decls = append(decls, e.wrap(jen.Id("ret").Dot("Add").Call(jen.Id(name).Call(fargs...))))
 	}
 
 	// generate the function that pieces togeather all the code
 	var codes []jen.Code
-	codes = append(codes, genNewJenFile(astFile.Name.String()))
+	codes = append(codes, cv.genNewJenFile(astFile.Name))
 	// add anon imports i.e. _ for side effects
 	if len(anonImports) > 0 {
 		codes = append(codes, jen.Id("ret").Dot("Anon").Call(anonImports...))
 	}
 	// add the generated functions to the created jen file
-	for _, name := range decls {
-		codes = append(codes, jen.Id("ret").Dot("Add").Call(jen.Id(name).Call()))
-	}
+	codes = append(codes, decls...)
 	// return the created jen file
 	codes = append(codes, jen.Return().Id("ret"))
 	// add the patch function to the output file
-	file.Add(
-		jen.Func().Id("genFile").Params().Op("*").Qual(jenImp, "File").Block(codes...),
+	ret = append(ret,
+		jen.Func().Id(genName).Params(params...).Op("*").Qual(jenImp, "File").Block(codes...),
 	)
-	// if main then generate a main function that prints out the output of the
-	// patch function
-	if main {
-		file.Add(genMainFunc())
+	cv.reportFile(astFile, genName)
+	return ret
+}
+
+// declEntries returns the planned declarations of the file or, if there is no
+// plan, every declaration once
+func (cv *converter) declEntries(f *ast.File) []entry {
+	if p := cv.plan(f); p != nil {
+		return p
 	}
-	return file
+	var ret []entry
+	for _, decl := range f.Decls {
+		ret = append(ret, entry{node: decl})
+	}
+	return ret
+}
+
+func uniqueName(name string, used map[string]bool) string {
+	ret := name
+	for i := 2; used[ret]; i++ {
+		ret = name + strconv.Itoa(i)
+	}
+	used[ret] = true
+	return ret
 }
 
-func genNewJenFile(name string) jen.Code {
-	return jen.Id("ret").Op(":=").Qual(jenImp, "NewFile").Call(jen.Lit(name))
+func (cv *converter) genNewJenFile(pkg *ast.Ident) jen.Code {
+	return jen.Id("ret").Op(":=").Qual(jenImp, "NewFile").Call(cv.name(pkg))
 }
 
-func genMainFunc() jen.Code {
+func (cv *converter) genMainFunc() jen.Code {
+	if cv.tmpl != nil && cv.tmpl.main != nil {
+		return jen.Func().Id("main").Params().Block(cv.tmpl.main...)
+	}
 	return jen.Func().Id("main").Params().Block(
 		jen.Id("ret").Op(":=").Id("genFile").Call(),
 		jen.Qual("fmt", "Printf").Call(
@@ -133,21 +355,40 @@ func genMainFunc() jen.Code {
 	)
 }
 
-func makeJenCode(s ast.Decl) (jen.Code, string) {
+func (cv *converter) declName(s ast.Decl) string {
+	if cv.naming == "name" {
+		switch t := s.(type) {
+		case *ast.GenDecl:
+			if names := declNames(t); len(names) > 0 {
+				return "gen" + exported(t.Tok.String()) + exported(names[0])
+			}
+		case *ast.FuncDecl:
+			if t.Recv != nil && len(t.Recv.List) > 0 {
+				return "genFunc" + exported(recvName(t.Recv.List[0].Type)) + exported(t.Name.Name)
+			}
+		}
+	}
+	switch t := s.(type) {
+	case *ast.GenDecl:
+		return "genDeclAt" + strconv.Itoa(int(t.TokPos))
+	case *ast.FuncDecl:
+		return "genFunc" + t.Name.String()
+	}
+	return ""
+}
+
+func (cv *converter) makeJenCode(s ast.Decl, name string, params ...jen.Code) jen.Code {
 	inner := jen.Null()
-	name := ""
 	switch t := s.(type) {
 	case *ast.GenDecl:
-		name = "genDeclAt" + strconv.Itoa(int(t.TokPos))
-		inner.Add(genDecl(t))
+		inner.Add(cv.genDecl(t))
 	case *ast.FuncDecl:
-		name = "genFunc" + t.Name.String()
-		inner.Add(funcDecl(t))
+		inner.Add(cv.funcDecl(t))
 	}
-	return makeJenFileFunc(name, inner), name
+	return makeJenFileFunc(name, inner, params...)
 }
-func makeJenFileFunc(name string, block jen.Code) jen.Code {
-	return jen.Func().Id(name).Params().Qual(jenImp, "Code").Block(
+func makeJenFileFunc(name string, block jen.Code, params ...jen.Code) jen.Code {
+	return jen.Func().Id(name).Params(params...).Qual(jenImp, "Code").Block(
 		jen.Return().Add(block),
 	)
 }
@@ -161,39 +402,81 @@ func parseFile(code []byte) *ast.File {
 	return f
 }
 
-func genDecl(g *ast.GenDecl) jen.Code {
+func (cv *converter) genDecl(g *ast.GenDecl) (code jen.Code) {
+	if cv.lenient {
+		defer cv.recoverVerbatim(g, &code, true)
+	}
+	if c, ok := cv.subst(g); ok {
+		return c
+	}
 	ret := jen.Qual(jenImp, "Null").Call()
+	var keyword string
+	switch g.Tok {
+	case token.CONST:
+		keyword = "Const"
+	case token.VAR:
+		keyword = "Var"
+	case token.TYPE:
+		keyword = "Type"
+	default:
+		return ret
+	}
+	if g.Lparen.IsValid() {
+		ret.Dot(keyword).Call()
+		ret.Add(cv.group(g, "Defs", specNodes(g.Specs), cv.specCode))
+		return ret
+	}
 	for _, spec := range g.Specs {
-		switch s := spec.(type) {
-		case *ast.ValueSpec:
-			ret.Add(valueSpec(s))
-		case *ast.TypeSpec:
-			ret.Add(typeSpec(s))
-		}
+		ret.Dot(keyword).Call().Add(cv.genSpec(spec))
 	}
 	return ret
 }
 
-func typeSpec(s *ast.TypeSpec) jen.Code {
-	return jen.Dot("Type").Call().Add(ident(s.Name)).Add(genExpr(s.Type))
+func (cv *converter) genSpec(spec ast.Spec) jen.Code {
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		return cv.valueSpec(s)
+	case *ast.TypeSpec:
+		return cv.typeSpec(s)
+	}
+	return jen.Null()
+}
+
+func (cv *converter) typeSpec(s *ast.TypeSpec) jen.Code {
+	if s.TypeParams != nil {
+		if cv.lenient {
+			cv.warn(s.TypeParams, "generics", "type parameters are not supported, the source is kept verbatim")
+			return verbatim(s, false)
+		}
+		cv.warn(s.TypeParams, "generics", "type parameters are dropped")
+	}
+	ret := jen.Add(cv.ident(s.Name))
+	if s.Assign.IsValid() {
+		ret.Dot("Op").Call(jen.Lit("="))
+	}
+	return ret.Add(cv.genExpr(s.Type))
 }
 
-func valueSpec(s *ast.ValueSpec) jen.Code {
-	ret := jen.Dot("Var").Call()
-	ret.Add(identsList(s.Names))
-	ret.Add(genExpr(s.Type))
+func (cv *converter) valueSpec(s *ast.ValueSpec) jen.Code {
+	ret := jen.Add(cv.identsList(s.Names))
+	ret.Add(cv.genExpr(s.Type))
 	if len(s.Values) > 0 {
 		ret.Dot("Op").Call(jen.Lit("="))
-		ret.Add(genExprs(s.Values))
+		ret.Add(cv.genExprs(s.Values))
 	}
 	return ret
 }
 
-func basicLit(b *ast.BasicLit) jen.Code {
+func (cv *converter) basicLit(b *ast.BasicLit) jen.Code {
 	switch b.Kind {
 	case token.INT:
 		i, err := strconv.ParseInt(b.Value, 10, 32)
 		if err != nil {
+			if cv.lenient {
+				cv.warn(b, "literal", "integer literal %s is kept verbatim", b.Value)
+				return verbatim(b, false)
+			}
+			cv.warn(b, "literal", "integer literal %s is dropped, only decimal int32 values are supported", b.Value)
 			return nil
 		}
 		return jen.Dot("Lit").Call(jen.Lit(int(i)))
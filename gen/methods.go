@@ -0,0 +1,516 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// methodSet is an interface whose generator takes a list of Method instead
+// of the fixed method set of the source. impl is the type implementing it in
+// the source and tmpl the method of impl all methods are generated from.
+type methodSet struct {
+	name    string
+	decl    *ast.GenDecl
+	methods []methodInfo
+	impl    string
+	tmpl    *ast.FuncDecl
+	// data is the variable holding the methods of the source
+	data string
+}
+
+type methodInfo struct {
+	name     string
+	params   []paramInfo
+	results  []paramInfo
+	variadic bool
+}
+
+type paramInfo struct {
+	name string
+	typ  ast.Expr
+}
+
+// methodsTemplate returns the template of a file whose interfaces listed in
+// names are generated from a slice of Method. The methods implementing them
+// are generated from the first of them found in the file, other declarations
+// of the implementing type take its name.
+func (cv *converter) methodsTemplate(f *ast.File, names []string) *template {
+	t := newTemplate()
+	var lower bool
+	var sets []*methodSet
+	for _, decl := range f.Decls {
+		if m := findInterface(decl, names); m != nil {
+			m.findImpl(f.Decls)
+			sets = append(sets, m)
+		}
+	}
+	if len(sets) == 0 {
+		return t
+	}
+
+	var plan []entry
+	for _, decl := range f.Decls {
+		m, kind := implRelated(decl, sets)
+		switch kind {
+		case declInterface:
+			t.substs[decl] = genInterfaceMethods()
+			plan = append(plan, entry{
+				node: decl,
+				name: "genInterface" + m.name,
+				params: []jen.Code{
+					jen.Id("name").String(),
+					jen.Id("methods").Index().Id("Method"),
+				},
+				args: []jen.Code{jen.Lit(m.name), jen.Id(m.data)},
+			})
+		case declTemplate:
+			m.templateMethod(t, &lower)
+			plan = append(plan, entry{
+				node:   decl,
+				rng:    jen.Id(m.data),
+				v:      "method",
+				typ:    jen.Id("Method"),
+				name:   "gen" + exported(m.impl) + "Method",
+				params: []jen.Code{jen.Id("name").String()},
+				args:   []jen.Code{jen.Lit(m.impl)},
+			})
+		case declImpl:
+			// generated by the loop over the methods
+		case declImplType:
+			typeNames(t, decl, typeSubs(m.impl, &lower))
+			plan = append(plan, entry{
+				node:   decl,
+				params: []jen.Code{jen.Id("name").String()},
+				args:   []jen.Code{jen.Lit(m.impl)},
+			})
+		default:
+			plan = append(plan, entry{node: decl})
+		}
+	}
+	t.plans[f] = plan
+
+	t.decls = append(t.decls, genMethodType(), genParamsFunc(), genArgsFunc(), genMethodsOf())
+	for _, m := range sets {
+		t.decls = append(t.decls, m.genData(cv))
+	}
+	if lower {
+		t.decls = append(t.decls, genLowerFirst())
+	}
+	return t
+}
+
+// findInterface returns the methods of decl when it declares a single
+// interface listed in names
+func findInterface(decl ast.Decl, names []string) *methodSet {
+	g, ok := decl.(*ast.GenDecl)
+	if !ok || g.Tok != token.TYPE || len(g.Specs) != 1 {
+		return nil
+	}
+	spec := g.Specs[0].(*ast.TypeSpec)
+	it, ok := spec.Type.(*ast.InterfaceType)
+	if !ok || !contains(names, spec.Name.String()) {
+		return nil
+	}
+	m := &methodSet{name: spec.Name.String(), decl: g, data: lowerFirst(spec.Name.String()) + "Methods"}
+	for _, field := range it.Methods.List {
+		// embedded interfaces are not expanded
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+		for _, name := range field.Names {
+			info := methodInfo{name: name.String()}
+			info.params, info.variadic = paramInfos(ft.Params)
+			info.results, _ = paramInfos(ft.Results)
+			m.methods = append(m.methods, info)
+		}
+	}
+	return m
+}
+
+func paramInfos(fl *ast.FieldList) ([]paramInfo, bool) {
+	var ret []paramInfo
+	variadic := false
+	if fl == nil {
+		return ret, variadic
+	}
+	for _, field := range fl.List {
+		typ := field.Type
+		if e, ok := typ.(*ast.Ellipsis); ok {
+			typ, variadic = e.Elt, true
+		}
+		if len(field.Names) == 0 {
+			ret = append(ret, paramInfo{typ: typ})
+		}
+		for _, name := range field.Names {
+			ret = append(ret, paramInfo{name: name.String(), typ: typ})
+		}
+	}
+	return ret, variadic
+}
+
+func (m *methodSet) method(name string) *methodInfo {
+	for i := range m.methods {
+		if m.methods[i].name == name {
+			return &m.methods[i]
+		}
+	}
+	return nil
+}
+
+// findImpl finds the type implementing the interface, the first method of
+// it is the template. Unnamed parameters are named after the parameters of
+// the implementation or their position so that they can be passed on.
+func (m *methodSet) findImpl(decls []ast.Decl) {
+	for _, decl := range decls {
+		f, ok := decl.(*ast.FuncDecl)
+		if !ok || recvType(decl) == "" || m.method(f.Name.String()) == nil {
+			continue
+		}
+		if m.impl == "" {
+			m.impl, m.tmpl = recvType(decl), f
+		}
+		if recvType(decl) != m.impl {
+			continue
+		}
+		info := m.method(f.Name.String())
+		params, _ := paramInfos(f.Type.Params)
+		for i := range info.params {
+			if info.params[i].name == "" && i < len(params) {
+				info.params[i].name = params[i].name
+			}
+		}
+	}
+	for i := range m.methods {
+		for j := range m.methods[i].params {
+			if m.methods[i].params[j].name == "" || m.methods[i].params[j].name == "_" {
+				m.methods[i].params[j].name = "p" + strconv.Itoa(j)
+			}
+		}
+	}
+}
+
+const (
+	declOther = iota
+	declInterface
+	declTemplate
+	declImpl
+	declImplType
+)
+
+// implRelated returns the method set a declaration belongs to and how
+func implRelated(decl ast.Decl, sets []*methodSet) (*methodSet, int) {
+	for _, m := range sets {
+		switch {
+		case decl == m.decl:
+			return m, declInterface
+		case m.impl == "":
+		case decl == m.tmpl:
+			return m, declTemplate
+		case recvType(decl) == m.impl && m.method(decl.(*ast.FuncDecl).Name.String()) != nil:
+			return m, declImpl
+		case recvType(decl) == m.impl || declares(decl, m.impl):
+			return m, declImplType
+		}
+	}
+	for _, m := range sets {
+		if _, ok := decl.(*ast.FuncDecl); !ok || m.impl == "" {
+			continue
+		}
+		for _, l := range leaves(decl) {
+			if id, ok := l.(*ast.Ident); ok && id.Name == m.impl {
+				return m, declImplType
+			}
+		}
+	}
+	return nil, declOther
+}
+
+// declares reports whether decl declares the type name
+func declares(decl ast.Decl, name string) bool {
+	g, ok := decl.(*ast.GenDecl)
+	if !ok || g.Tok != token.TYPE {
+		return false
+	}
+	for _, spec := range g.Specs {
+		if spec.(*ast.TypeSpec).Name.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// templateMethod makes the generator build the template method from a Method
+// bound to method. The signature is taken from the Method, calls passing on
+// all parameters pass on the parameters of the Method and the name of the
+// method is replaced in identifiers and strings.
+func (m *methodSet) templateMethod(t *template, lower *bool) {
+	fn := m.tmpl
+	method := jen.Id("method")
+	t.substs[fn.Type] = jen.Dot("Params").Call(
+		jen.Id("genParams").Call(method.Clone().Dot("Params"), method.Clone().Dot("Variadic")).Op("..."),
+	).Dot("Params").Call(
+		jen.Id("genParams").Call(method.Clone().Dot("Results"), jen.False()).Op("..."),
+	)
+
+	nameSub := textSub{val: fn.Name.String(), code: method.Clone().Dot("Name"), camel: true}
+	forwarded := map[ast.Node]bool{}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok && forwards(c, fn.Type.Params, nameSub) {
+			t.calls[c] = jen.Id("genArgs").Call(method.Clone()).Op("...")
+			forwarded[c] = true
+		}
+		return true
+	})
+
+	// a forwarded call is only returned when the method has results
+	if fn.Body != nil {
+		var plan []entry
+		found := false
+		for _, s := range fn.Body.List {
+			r, ok := s.(*ast.ReturnStmt)
+			if !ok || len(r.Results) != 1 || !forwarded[r.Results[0]] {
+				plan = append(plan, entry{node: s})
+				continue
+			}
+			results := jen.Len(method.Clone().Dot("Results"))
+			plan = append(plan,
+				entry{node: s, cond: results.Clone().Op(">").Lit(0)},
+				entry{node: &ast.ExprStmt{X: r.Results[0]}, cond: results.Clone().Op("==").Lit(0)},
+			)
+			found = true
+		}
+		if found {
+			t.plans[fn.Body] = plan
+		}
+	}
+
+	typeNames(t, fn, append(typeSubs(m.impl, lower), nameSub))
+}
+
+// forwards reports whether the call passes on all parameters in order to a
+// function named after the method
+func forwards(c *ast.CallExpr, params *ast.FieldList, nameSub textSub) bool {
+	var names []string
+	variadic := false
+	for _, field := range params.List {
+		_, variadic = field.Type.(*ast.Ellipsis)
+		for _, name := range field.Names {
+			names = append(names, name.String())
+		}
+	}
+	if len(names) != len(c.Args) || variadic != c.Ellipsis.IsValid() {
+		return false
+	}
+	for i, arg := range c.Args {
+		if id, ok := arg.(*ast.Ident); !ok || id.Name != names[i] {
+			return false
+		}
+	}
+	fun := c.Fun
+	if sel, ok := fun.(*ast.SelectorExpr); ok {
+		fun = sel.Sel
+	}
+	id, ok := fun.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = textParts(id.Name, []textSub{nameSub}, false)
+	return ok
+}
+
+// genInterfaceMethods generates the declaration of an interface template
+func genInterfaceMethods() jen.Code {
+	method := jen.Id("method")
+	return jen.Qual(jenImp, "Type").Call().Dot("Id").Call(jen.Id("name")).Dot("InterfaceFunc").Call(
+		jen.Func().Params(jen.Id("g").Op("*").Qual(jenImp, "Group")).Block(
+			jen.For(jen.List(jen.Id("_"), method.Clone()).Op(":=").Range().Id("methods")).Block(
+				jen.Id("g").Dot("Id").Call(method.Clone().Dot("Name")).Dot("Params").Call(
+					jen.Id("genParams").Call(method.Clone().Dot("Params"), method.Clone().Dot("Variadic")).Op("..."),
+				).Dot("Params").Call(
+					jen.Id("genParams").Call(method.Clone().Dot("Results"), jen.False()).Op("..."),
+				),
+			),
+		),
+	)
+}
+
+func genMethodType() jen.Code {
+	return jen.Comment("Method describes a method of an interface").Line().
+		Type().Id("Method").Struct(
+		jen.Id("Name").String(),
+		jen.Id("Params").Index().Id("Param"),
+		jen.Id("Results").Index().Id("Param"),
+		jen.Id("Variadic").Bool(),
+	).Line().Line().
+		Comment("Param is a parameter or result of a Method").Line().
+		Type().Id("Param").Struct(
+		jen.Id("Name").String(),
+		jen.Id("Type").Qual(jenImp, "Code"),
+	)
+}
+
+// genParamsFunc generates the function turning a Param list into the code of
+// a parameter list
+func genParamsFunc() jen.Code {
+	return jen.Func().Id("genParams").Params(
+		jen.Id("params").Index().Id("Param"),
+		jen.Id("variadic").Bool(),
+	).Index().Qual(jenImp, "Code").Block(
+		jen.Var().Id("ret").Index().Qual(jenImp, "Code"),
+		jen.For(jen.List(jen.Id("i"), jen.Id("p")).Op(":=").Range().Id("params")).Block(
+			jen.Id("c").Op(":=").Qual(jenImp, "Null").Call(),
+			jen.If(jen.Id("p").Dot("Name").Op("!=").Lit("")).Block(
+				jen.Id("c").Dot("Id").Call(jen.Id("p").Dot("Name")),
+			),
+			jen.If(jen.Id("variadic").Op("&&").Id("i").Op("==").Len(jen.Id("params")).Op("-").Lit(1)).Block(
+				jen.Id("c").Dot("Op").Call(jen.Lit("...")),
+			),
+			jen.Id("ret").Op("=").Append(jen.Id("ret"), jen.Id("c").Dot("Add").Call(jen.Id("p").Dot("Type"))),
+		),
+		jen.Return().Id("ret"),
+	)
+}
+
+// genArgsFunc generates the function passing on the parameters of a Method
+func genArgsFunc() jen.Code {
+	return jen.Func().Id("genArgs").Params(jen.Id("m").Id("Method")).Index().Qual(jenImp, "Code").Block(
+		jen.Var().Id("ret").Index().Qual(jenImp, "Code"),
+		jen.For(jen.List(jen.Id("i"), jen.Id("p")).Op(":=").Range().Id("m").Dot("Params")).Block(
+			jen.Id("c").Op(":=").Qual(jenImp, "Id").Call(jen.Id("p").Dot("Name")),
+			jen.If(jen.Id("m").Dot("Variadic").Op("&&").Id("i").Op("==").Len(jen.Id("m").Dot("Params")).Op("-").Lit(1)).Block(
+				jen.Id("c").Dot("Op").Call(jen.Lit("...")),
+			),
+			jen.Id("ret").Op("=").Append(jen.Id("ret"), jen.Id("c")),
+		),
+		jen.Return().Id("ret"),
+	)
+}
+
+// genMethodsOf generates the functions building the Method list of a loaded
+// interface
+func genMethodsOf() jen.Code {
+	types := "go/types"
+	t := jen.Id("t")
+	return jen.Comment("methodsOf returns the methods of a loaded interface").Line().
+		Func().Id("methodsOf").Params(jen.Id("iface").Op("*").Qual(types, "Interface")).Index().Id("Method").Block(
+		jen.Var().Id("ret").Index().Id("Method"),
+		jen.For(jen.Id("i").Op(":=").Lit(0), jen.Id("i").Op("<").Id("iface").Dot("NumMethods").Call(), jen.Id("i").Op("++")).Block(
+			jen.Id("fn").Op(":=").Id("iface").Dot("Method").Call(jen.Id("i")),
+			jen.Id("sig").Op(":=").Id("fn").Dot("Type").Call().Assert(jen.Op("*").Qual(types, "Signature")),
+			jen.Id("m").Op(":=").Id("Method").Values(jen.Dict{
+				jen.Id("Name"):     jen.Id("fn").Dot("Name").Call(),
+				jen.Id("Params"):   jen.Id("paramsOf").Call(jen.Id("sig").Dot("Params").Call()),
+				jen.Id("Results"):  jen.Id("paramsOf").Call(jen.Id("sig").Dot("Results").Call()),
+				jen.Id("Variadic"): jen.Id("sig").Dot("Variadic").Call(),
+			}),
+			jen.For(jen.Id("j").Op(":=").Range().Id("m").Dot("Params")).Block(
+				jen.If(jen.Id("m").Dot("Params").Index(jen.Id("j")).Dot("Name").Op("==").Lit("")).Block(
+					jen.Id("m").Dot("Params").Index(jen.Id("j")).Dot("Name").Op("=").Qual("fmt", "Sprint").Call(jen.Lit("p"), jen.Id("j")),
+				),
+			),
+			jen.If(jen.Id("m").Dot("Variadic")).Block(
+				jen.Id("last").Op(":=").Id("sig").Dot("Params").Call().Dot("At").Call(jen.Id("sig").Dot("Params").Call().Dot("Len").Call().Op("-").Lit(1)),
+				jen.Id("m").Dot("Params").Index(jen.Len(jen.Id("m").Dot("Params")).Op("-").Lit(1)).Dot("Type").Op("=").Id("typeCode").Call(
+					jen.Id("last").Dot("Type").Call().Assert(jen.Op("*").Qual(types, "Slice")).Dot("Elem").Call(),
+				),
+			),
+			jen.Id("ret").Op("=").Append(jen.Id("ret"), jen.Id("m")),
+		),
+		jen.Return().Id("ret"),
+	).Line().Line().
+		Func().Id("paramsOf").Params(t.Clone().Op("*").Qual(types, "Tuple")).Index().Id("Param").Block(
+		jen.Var().Id("ret").Index().Id("Param"),
+		jen.For(jen.Id("i").Op(":=").Lit(0), jen.Id("i").Op("<").Add(t.Clone()).Dot("Len").Call(), jen.Id("i").Op("++")).Block(
+			jen.Id("v").Op(":=").Add(t.Clone()).Dot("At").Call(jen.Id("i")),
+			jen.Id("ret").Op("=").Append(jen.Id("ret"), jen.Id("Param").Values(jen.Dict{
+				jen.Id("Name"): jen.Id("v").Dot("Name").Call(),
+				jen.Id("Type"): jen.Id("typeCode").Call(jen.Id("v").Dot("Type").Call()),
+			})),
+		),
+		jen.Return().Id("ret"),
+	).Line().Line().
+		Func().Id("typeCode").Params(t.Clone().Qual(types, "Type")).Qual(jenImp, "Code").Block(
+		jen.Switch(t.Clone().Op(":=").Add(t.Clone()).Assert(jen.Type())).Block(
+			jen.Case(jen.Op("*").Qual(types, "Named")).Block(
+				jen.If(jen.Id("pkg").Op(":=").Add(t.Clone()).Dot("Obj").Call().Dot("Pkg").Call(), jen.Id("pkg").Op("!=").Nil()).Block(
+					jen.Return().Qual(jenImp, "Qual").Call(jen.Id("pkg").Dot("Path").Call(), t.Clone().Dot("Obj").Call().Dot("Name").Call()),
+				),
+			),
+			jen.Case(jen.Op("*").Qual(types, "Pointer")).Block(
+				jen.Return().Qual(jenImp, "Op").Call(jen.Lit("*")).Dot("Add").Call(jen.Id("typeCode").Call(t.Clone().Dot("Elem").Call())),
+			),
+			jen.Case(jen.Op("*").Qual(types, "Slice")).Block(
+				jen.Return().Qual(jenImp, "Index").Call().Dot("Add").Call(jen.Id("typeCode").Call(t.Clone().Dot("Elem").Call())),
+			),
+			jen.Case(jen.Op("*").Qual(types, "Map")).Block(
+				jen.Return().Qual(jenImp, "Map").Call(jen.Id("typeCode").Call(t.Clone().Dot("Key").Call())).Dot("Add").Call(jen.Id("typeCode").Call(t.Clone().Dot("Elem").Call())),
+			),
+			jen.Case(jen.Op("*").Qual(types, "Array")).Block(
+				jen.Return().Qual(jenImp, "Index").Call(jen.Qual(jenImp, "Lit").Call(jen.Int().Call(t.Clone().Dot("Len").Call()))).Dot("Add").Call(jen.Id("typeCode").Call(t.Clone().Dot("Elem").Call())),
+			),
+			jen.Case(jen.Op("*").Qual(types, "Chan")).Block(
+				jen.Id("elem").Op(":=").Id("typeCode").Call(t.Clone().Dot("Elem").Call()),
+				jen.Switch(t.Clone().Dot("Dir").Call()).Block(
+					jen.Case(jen.Qual(types, "SendOnly")).Block(
+						jen.Return().Qual(jenImp, "Chan").Call().Dot("Op").Call(jen.Lit("<-")).Dot("Add").Call(jen.Id("elem")),
+					),
+					jen.Case(jen.Qual(types, "RecvOnly")).Block(
+						jen.Return().Qual(jenImp, "Op").Call(jen.Lit("<-")).Dot("Chan").Call().Dot("Add").Call(jen.Id("elem")),
+					),
+				),
+				jen.Return().Qual(jenImp, "Chan").Call().Dot("Add").Call(jen.Id("elem")),
+			),
+			jen.Case(jen.Op("*").Qual(types, "Signature")).Block(
+				jen.Id("params").Op(":=").Id("paramsOf").Call(t.Clone().Dot("Params").Call()),
+				jen.If(t.Clone().Dot("Variadic").Call()).Block(
+					jen.Id("last").Op(":=").Add(t.Clone()).Dot("Params").Call().Dot("At").Call(t.Clone().Dot("Params").Call().Dot("Len").Call().Op("-").Lit(1)),
+					jen.Id("params").Index(jen.Len(jen.Id("params")).Op("-").Lit(1)).Dot("Type").Op("=").Id("typeCode").Call(
+						jen.Id("last").Dot("Type").Call().Assert(jen.Op("*").Qual(types, "Slice")).Dot("Elem").Call(),
+					),
+				),
+				jen.Return().Qual(jenImp, "Func").Call().Dot("Params").Call(jen.Id("genParams").Call(jen.Id("params"), t.Clone().Dot("Variadic").Call()).Op("...")).Dot("Params").Call(
+					jen.Id("genParams").Call(jen.Id("paramsOf").Call(t.Clone().Dot("Results").Call()), jen.False()).Op("..."),
+				),
+			),
+		),
+		jen.Comment("the packages of the other types are named, but not imported"),
+		jen.Return().Qual(jenImp, "Id").Call(jen.Qual(types, "TypeString").Call(t.Clone(), jen.Func().Params(jen.Id("p").Op("*").Qual(types, "Package")).String().Block(
+			jen.Return().Id("p").Dot("Name").Call(),
+		))),
+	)
+}
+
+// genData generates the variable holding the methods of the source interface
+func (m *methodSet) genData(cv *converter) jen.Code {
+	var methods []jen.Code
+	for _, info := range m.methods {
+		d := jen.Dict{jen.Id("Name"): jen.Lit(info.name)}
+		if len(info.params) > 0 {
+			d[jen.Id("Params")] = cv.genParamData(info.params)
+		}
+		if len(info.results) > 0 {
+			d[jen.Id("Results")] = cv.genParamData(info.results)
+		}
+		if info.variadic {
+			d[jen.Id("Variadic")] = jen.True()
+		}
+		methods = append(methods, jen.Values(d))
+	}
+	return jen.Var().Id(m.data).Op("=").Index().Id("Method").Values(methods...)
+}
+
+func (cv *converter) genParamData(params []paramInfo) jen.Code {
+	var ret []jen.Code
+	for _, p := range params {
+		d := jen.Dict{jen.Id("Type"): cv.codeValue(p.typ)}
+		if p.name != "" {
+			d[jen.Id("Name")] = jen.Lit(p.name)
+		}
+		ret = append(ret, jen.Values(d))
+	}
+	return jen.Index().Id("Param").Values(ret...)
+}
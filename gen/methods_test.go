@@ -0,0 +1,145 @@
+package gen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aloder/tojen/run"
+	"github.com/dave/jennifer/jen"
+	"github.com/stretchr/testify/assert"
+)
+
+var methodsTests = []tcg{
+	tcg{
+		"tracing decorator",
+		`package store
+
+import (
+	"context"
+	"log"
+)
+
+type Store interface {
+	Get(ctx context.Context, key string) (string, error)
+	Put(ctx context.Context, key string, value []byte) error
+	Keys(prefix string, opts ...Option) []string
+	Close()
+}
+type tracedStore struct {
+	next Store
+}
+
+func newTracedStore(next Store) *tracedStore {
+	return &tracedStore{next: next}
+}
+func (t *tracedStore) Get(ctx context.Context, key string) (string, error) {
+	log.Println("tracedStore.Get")
+	return t.next.Get(ctx, key)
+}
+func (t *tracedStore) Put(ctx context.Context, key string, value []byte) error {
+	log.Println("tracedStore.Put")
+	return t.next.Put(ctx, key, value)
+}
+func (t *tracedStore) Keys(prefix string, opts ...Option) []string {
+	log.Println("tracedStore.Keys")
+	return t.next.Keys(prefix, opts...)
+}
+func (t *tracedStore) Close() {
+	log.Println("tracedStore.Close")
+	t.next.Close()
+}
+`,
+	},
+	tcg{
+		"mock",
+		`package store
+
+type Store interface {
+	Get(key string) (string, error)
+	Delete(key string) error
+}
+type mockStore struct {
+	GetFunc    func(key string) (string, error)
+	DeleteFunc func(key string) error
+	calls      []string
+}
+
+func (m *mockStore) Get(key string) (string, error) {
+	m.calls = append(m.calls, "Get")
+	return m.GetFunc(key)
+}
+func (m *mockStore) Delete(key string) error {
+	m.calls = append(m.calls, "Delete")
+	return m.DeleteFunc(key)
+}
+`,
+	},
+}
+
+func TestMethods(t *testing.T) {
+	for _, tc := range methodsTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			gen := roundTrip(t, test.Code, Options{Interfaces: []string{"Store"}})
+			assert.Contains(t, gen, "range storeMethods")
+		})
+	}
+}
+
+const methodsOfMain = `package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/jennifer/jen"
+)
+
+const src = ` + "`" + `package p
+
+import "io"
+
+type Store interface {
+	Watch(func(key string, r io.Reader) error, ...chan<- [2]io.Writer) <-chan int
+}
+` + "`" + `
+
+func main() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		panic(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		panic(err)
+	}
+	iface := pkg.Scope().Lookup("Store").Type().Underlying().(*types.Interface)
+	for _, m := range methodsOf(iface) {
+		fmt.Printf("%#v\n", jen.Func().Id(m.Name).Params(genParams(m.Params, m.Variadic)...).Params(genParams(m.Results, false)...).Block())
+	}
+}
+`
+
+func TestMethodsOf(t *testing.T) {
+	file := jen.NewFile("main")
+	for _, c := range []jen.Code{genMethodType(), genParamsFunc(), genMethodsOf()} {
+		file.Add(c)
+	}
+	b := &bytes.Buffer{}
+	if err := file.Render(b); err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	out, err := run.ExecFiles(map[string]string{"helpers.go": b.String(), "main.go": methodsOfMain})
+	if err != nil {
+		assert.Nil(t, err, b.String())
+		return
+	}
+	assert.Equal(t, "func Watch(p0 func(key string, r io.Reader) error, p1 ...chan<- [2]io.Writer) <-chan int {}\n", *out)
+}
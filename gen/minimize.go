@@ -0,0 +1,347 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+)
+
+// MinimizeVerify returns the smallest file made from src that fails to
+// verify the way src does: with the same reason for a *Divergence, or else
+// with the same error apart from positions.
+func MinimizeVerify(name string, src []byte) ([]byte, error) {
+	err := Verify(name, src)
+	if err == nil {
+		return nil, errors.New("the file does not fail")
+	}
+	want := failure(err)
+	return Minimize(src, func(b []byte) bool {
+		err := Verify(name, b)
+		return err != nil && failure(err) == want
+	})
+}
+
+var positions = regexp.MustCompile(`:\d+(:\d+)?`)
+
+// failure returns what tells a failure of Verify apart from others
+func failure(err error) string {
+	if d, ok := err.(*Divergence); ok {
+		return d.Reason
+	}
+	return positions.ReplaceAllString(err.Error(), "")
+}
+
+// Minimize returns the smallest file made from src by removing declarations,
+// statements and parts of expressions for which fails still returns true. The
+// nodes of one kind at one depth are reduced together by delta debugging, so
+// a failing file of thousands of lines takes a few hundred calls to fails.
+func Minimize(src []byte, fails func([]byte) bool) ([]byte, error) {
+	cur, err := format.Source(src)
+	if err != nil {
+		return nil, err
+	}
+	if !fails(cur) {
+		return nil, errors.New("the file does not fail")
+	}
+	// comments are left out of a round trip
+	if b, err := stripComments(cur); err == nil && !bytes.Equal(b, cur) && fails(b) {
+		cur = b
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, kind := range []editKind{declEdits, stmtEdits, exprEdits} {
+			for depth := 0; ; depth++ {
+				edits, err := collectEdits(cur, kind, depth)
+				if err != nil {
+					return nil, err
+				}
+				if len(edits) == 0 {
+					break
+				}
+				if b := ddmin(cur, edits, fails); b != nil {
+					cur, changed = b, true
+				}
+			}
+		}
+	}
+	return cur, nil
+}
+
+// edit replaces the source of a node with text
+type edit struct {
+	node       ast.Node
+	start, end int
+	text       string
+}
+
+// editKind returns the edits a node offers on itself or its children
+type editKind func(e *editor, n ast.Node) []edit
+
+type editor struct {
+	fset *token.FileSet
+	src  []byte
+}
+
+func (e *editor) offset(p token.Pos) int {
+	return e.fset.Position(p).Offset
+}
+
+// remove returns the edit removing n, with its line when n is alone on it
+func (e *editor) remove(n ast.Node) edit {
+	start, end := e.offset(n.Pos()), e.offset(n.End())
+	ls, le := start, end
+	for ls > 0 && (e.src[ls-1] == ' ' || e.src[ls-1] == '\t') {
+		ls--
+	}
+	for le < len(e.src) && (e.src[le] == ' ' || e.src[le] == '\t') {
+		le++
+	}
+	if (ls == 0 || e.src[ls-1] == '\n') && le < len(e.src) && e.src[le] == '\n' {
+		start, end = ls, le+1
+	}
+	return edit{node: n, start: start, end: end}
+}
+
+// replace returns the edit replacing n with the source of with and suffix
+func (e *editor) replace(n, with ast.Node, suffix string) edit {
+	text := string(e.src[e.offset(with.Pos()):e.offset(with.End())]) + suffix
+	return edit{node: n, start: e.offset(n.Pos()), end: e.offset(n.End()), text: text}
+}
+
+// declEdits removes declarations, the specs of grouped declarations and the
+// fields of structs and interfaces
+func declEdits(e *editor, n ast.Node) []edit {
+	var ret []edit
+	switch t := n.(type) {
+	case *ast.File:
+		for _, d := range t.Decls {
+			ret = append(ret, e.remove(d))
+		}
+	case *ast.GenDecl:
+		if t.Lparen.IsValid() {
+			for _, s := range t.Specs {
+				ret = append(ret, e.remove(s))
+			}
+		}
+	case *ast.StructType:
+		for _, f := range t.Fields.List {
+			ret = append(ret, e.remove(f))
+		}
+	case *ast.InterfaceType:
+		for _, f := range t.Methods.List {
+			ret = append(ret, e.remove(f))
+		}
+	}
+	return ret
+}
+
+// stmtEdits removes the statements of blocks and clauses
+func stmtEdits(e *editor, n ast.Node) []edit {
+	var list []ast.Stmt
+	switch t := n.(type) {
+	case *ast.BlockStmt:
+		list = t.List
+	case *ast.CaseClause:
+		list = t.Body
+	case *ast.CommClause:
+		list = t.Body
+	}
+	var ret []edit
+	for _, s := range list {
+		ret = append(ret, e.remove(s))
+	}
+	return ret
+}
+
+// exprEdits replaces an expression with a part of it
+func exprEdits(e *editor, n ast.Node) []edit {
+	switch t := n.(type) {
+	case *ast.BinaryExpr:
+		return []edit{e.replace(t, t.X, "")}
+	case *ast.ParenExpr:
+		// the parentheses of an operation may be needed
+		switch t.X.(type) {
+		case *ast.BinaryExpr, *ast.UnaryExpr, *ast.StarExpr:
+		default:
+			return []edit{e.replace(t, t.X, "")}
+		}
+	case *ast.UnaryExpr:
+		return []edit{e.replace(t, t.X, "")}
+	case *ast.IndexExpr:
+		return []edit{e.replace(t, t.X, "")}
+	case *ast.SliceExpr:
+		return []edit{e.replace(t, t.X, "")}
+	case *ast.TypeAssertExpr:
+		if t.Type != nil {
+			return []edit{e.replace(t, t.X, "")}
+		}
+	case *ast.CallExpr:
+		if len(t.Args) > 0 {
+			return []edit{e.replace(t, t.Fun, "()")}
+		}
+	case *ast.CompositeLit:
+		if len(t.Elts) > 0 {
+			if t.Type == nil {
+				return []edit{{node: t, start: e.offset(t.Pos()), end: e.offset(t.End()), text: "{}"}}
+			}
+			return []edit{e.replace(t, t.Type, "{}")}
+		}
+	}
+	return nil
+}
+
+// collectEdits returns the edits of kind in src that have depth edits of the
+// same kind around them, which never overlap
+func collectEdits(src []byte, kind editKind, depth int) ([]edit, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	e := &editor{fset: fset, src: src}
+	byNode := map[ast.Node]edit{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n != nil {
+			for _, ed := range kind(e, n) {
+				byNode[ed.node] = ed
+			}
+		}
+		return true
+	})
+	var ret []edit
+	var stack []bool
+	d := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			if stack[len(stack)-1] {
+				d--
+			}
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		ed, ok := byNode[n]
+		if ok && d == depth {
+			ret = append(ret, ed)
+		}
+		if ok {
+			d++
+		}
+		stack = append(stack, ok)
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool { return ret[i].start < ret[j].start })
+	return ret, nil
+}
+
+// ddmin finds a small set of edits to leave out such that src with the
+// others applied still fails, and returns that source or nil when no edit
+// can be applied
+func ddmin(src []byte, edits []edit, fails func([]byte) bool) []byte {
+	results := map[string]bool{}
+	// test applies the edits but those of keep
+	test := func(keep []int) []byte {
+		kept := map[int]bool{}
+		for _, k := range keep {
+			kept[k] = true
+		}
+		b := &bytes.Buffer{}
+		last := 0
+		for i, ed := range edits {
+			if kept[i] {
+				continue
+			}
+			b.Write(src[last:ed.start])
+			b.WriteString(ed.text)
+			last = ed.end
+		}
+		b.Write(src[last:])
+		code, err := format.Source(b.Bytes())
+		if err != nil {
+			return nil
+		}
+		failed, ok := results[string(code)]
+		if !ok {
+			failed = fails(code)
+			results[string(code)] = failed
+		}
+		if !failed {
+			return nil
+		}
+		return code
+	}
+
+	if b := test(nil); b != nil {
+		return b
+	}
+	var best []byte
+	keep := make([]int, len(edits))
+	for i := range keep {
+		keep[i] = i
+	}
+	n := 2
+	for len(keep) > 1 {
+		if n > len(keep) {
+			n = len(keep)
+		}
+		chunks := split(keep, n)
+		reduced := false
+		for _, c := range chunks {
+			if b := test(c); b != nil {
+				keep, best, n, reduced = c, b, 2, true
+				break
+			}
+		}
+		if !reduced && n > 2 {
+			for i := range chunks {
+				var comp []int
+				for j, c := range chunks {
+					if j != i {
+						comp = append(comp, c...)
+					}
+				}
+				if b := test(comp); b != nil {
+					keep, best, n, reduced = comp, b, n-1, true
+					break
+				}
+			}
+		}
+		if !reduced {
+			if n == len(keep) {
+				break
+			}
+			n *= 2
+		}
+	}
+	return best
+}
+
+// split splits s into n parts of about the same length
+func split(s []int, n int) [][]int {
+	var ret [][]int
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(s)-start)/(n-i)
+		ret = append(ret, s[start:end])
+		start = end
+	}
+	return ret
+}
+
+// stripComments returns src without its comments
+func stripComments(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	if err := format.Node(b, fset, f); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package gen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinimize(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+// User is a user
+type User struct {
+	Name string
+	Age  int
+}
+
+var (
+	a = 1
+	b = []int{1, 2, 3}
+)
+
+func main() {
+	u := User{Name: "x", Age: a + 2}
+	for i := range b {
+		if i > 0 {
+			fmt.Println(u.Name, (b[i] + 1) * 2)
+		}
+		fmt.Println("done")
+	}
+}
+`
+	// the failure needs the struct and the multiplication in the loop
+	calls := 0
+	fails := func(b []byte) bool {
+		calls++
+		return bytes.Contains(b, []byte("type User struct")) && bytes.Contains(b, []byte("*2"))
+	}
+	b, err := Minimize([]byte(src), fails)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	want := `package main
+
+type User struct {
+}
+
+func main() {
+	for i := range b {
+		if i {
+			fmt.Println(u.Name, b*2)
+		}
+	}
+}
+`
+	assert.Equal(t, want, string(b))
+	assert.True(t, calls < 100, calls)
+
+	_, err = Minimize([]byte(src), func([]byte) bool { return false })
+	assert.NotNil(t, err)
+}
+
+func TestMinimizeVerify(t *testing.T) {
+	// the name of the package is guessed wrong from the path
+	src := `package main
+
+import (
+	"fmt"
+
+	"github.com/x/y"
+)
+
+type User struct {
+	Name string
+}
+
+func main() {
+	fmt.Println(User{Name: "a"}, y.Z(1))
+}
+`
+	b, err := MinimizeVerify("y.go", []byte(src))
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	want := "package main\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/x/y\"\n)\n\nfunc main() {\n\tfmt.Println()\n}\n"
+	assert.Equal(t, want, string(b))
+}
@@ -0,0 +1,238 @@
+package gen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// fileGen is the generator of one file of a package
+type fileGen struct {
+	// name is the name of the source file
+	name string
+	// fn is the name of the function generating the file
+	fn   string
+	code []jen.Code
+}
+
+// GeneratePackage converts every file of a package, given by file name, into
+// one generator. Every file gets a genFile function named after it, and
+// genPackage returns all of the generated files by name. Code the converter
+// does not support is an error naming the file rather than a panic.
+func GeneratePackage(files map[string][]byte, packName string, main bool, opts Options) (*jen.File, error) {
+	gens, err := packageCode(files, opts)
+	if err != nil {
+		return nil, err
+	}
+	file := jen.NewFile(packName)
+	for _, g := range gens {
+		for _, c := range g.code {
+			file.Add(c)
+		}
+	}
+	file.Add(genPackageFunc(gens))
+	if main {
+		file.Add(genPackageMain())
+	}
+	return file, nil
+}
+
+// GeneratePackageBytes is GeneratePackage rendered
+func GeneratePackageBytes(files map[string][]byte, packName string, main bool, formating bool, opts Options) ([]byte, error) {
+	file, err := GeneratePackage(files, packName, main, opts)
+	if err != nil {
+		return nil, err
+	}
+	return renderFileWith(file, formating, opts)
+}
+
+// GeneratePackageFiles is GeneratePackageBytes with the generator of every
+// file in a file of the same name and genPackage in package.go
+func GeneratePackageFiles(files map[string][]byte, packName string, main bool, formating bool, opts Options) (map[string][]byte, error) {
+	gens, err := packageCode(files, opts)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string][]byte{}
+	used := map[string]bool{}
+	for _, g := range gens {
+		file := jen.NewFile(packName)
+		for _, c := range g.code {
+			file.Add(c)
+		}
+		b, err := renderFileWith(file, formating, opts)
+		if err != nil {
+			return nil, err
+		}
+		ret[g.name] = b
+		used[strings.TrimSuffix(g.name, ".go")] = true
+	}
+	file := jen.NewFile(packName)
+	file.Add(genPackageFunc(gens))
+	if main {
+		file.Add(genPackageMain())
+	}
+	b, err := renderFileWith(file, formating, opts)
+	if err != nil {
+		return nil, err
+	}
+	ret[uniqueName("package", used)+".go"] = b
+	return ret, nil
+}
+
+// packageCode converts the files in the order of their names. The names of
+// the generated functions are unique in the package and declarations the
+// templates of several files share are only added once.
+func packageCode(files map[string][]byte, opts Options) (ret []fileGen, err error) {
+	// the name of the file being converted when the converter panics
+	var name string
+	defer func() {
+		if err != nil && name != "" {
+			err = fmt.Errorf("%s: %v", filepath.Base(name), err)
+		}
+	}()
+	defer recoverConversion(&err)
+	if len(files) == 0 {
+		return nil, errors.New("no Go files in package")
+	}
+	var names []string
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	used := map[string]bool{"genPackage": true, "main": true}
+	seen := map[string]bool{}
+	for _, name = range names {
+		base := exported(strings.TrimSuffix(filepath.Base(name), ".go"))
+		cv := newConverter()
+		cv.src, cv.report = files[name], opts.Report.file(filepath.Base(name))
+		astFile := cv.parse(opts.Partial)
+		selectDecls(astFile, opts)
+		cv.tmpl = cv.fileTemplate(astFile, opts, lowerFirst(base))
+		g := fileGen{name: filepath.Base(name), fn: uniqueName("genFile"+base, used)}
+		g.code = cv.fileCode(astFile, g.fn, used)
+		if cv.tmpl != nil {
+			for _, d := range cv.tmpl.decls {
+				key := fmt.Sprintf("%#v", d)
+				if !seen[key] {
+					seen[key] = true
+					g.code = append(g.code, d)
+				}
+			}
+		}
+		ret = append(ret, g)
+	}
+	return ret, nil
+}
+
+func genPackageFunc(gens []fileGen) jen.Code {
+	files := jen.Dict{}
+	for _, g := range gens {
+		files[jen.Lit(g.name)] = jen.Id(g.fn).Call()
+	}
+	return jen.Func().Id("genPackage").Params().Map(jen.String()).Op("*").Qual(jenImp, "File").Block(
+		jen.Return().Map(jen.String()).Op("*").Qual(jenImp, "File").Values(files),
+	)
+}
+
+// genPackageMain generates a main function printing the generated files as a
+// txtar archive in the order of their names
+func genPackageMain() jen.Code {
+	return jen.Func().Id("main").Params().Block(
+		jen.Id("files").Op(":=").Id("genPackage").Call(),
+		jen.Var().Id("names").Index().String(),
+		jen.For(jen.Id("name").Op(":=").Range().Id("files")).Block(
+			jen.Id("names").Op("=").Append(jen.Id("names"), jen.Id("name")),
+		),
+		jen.Qual("sort", "Strings").Call(jen.Id("names")),
+		jen.For(jen.List(jen.Id("_"), jen.Id("name")).Op(":=").Range().Id("names")).Block(
+			jen.Qual("fmt", "Printf").Call(jen.Lit("-- %s --\n%#v"), jen.Id("name"), jen.Id("files").Index(jen.Id("name"))),
+		),
+	)
+}
+
+// PackageDir returns the directory of a package given as a directory or as
+// an import path. Import paths are resolved without the network, in GOROOT or
+// in the module containing the working directory.
+func PackageDir(path string) (string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return path, nil
+	}
+	dir := filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path))
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, nil
+	}
+	root, module, err := findModule()
+	if err != nil {
+		return "", err
+	}
+	if path == module {
+		return root, nil
+	}
+	if strings.HasPrefix(path, module+"/") {
+		return filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path, module+"/"))), nil
+	}
+	return "", fmt.Errorf("cannot find package %s in GOROOT or module %s", path, module)
+}
+
+// findModule returns the root and path of the module containing the working
+// directory
+func findModule() (string, string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		f, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "module ") {
+					module := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+					if m, err := strconv.Unquote(module); err == nil {
+						module = m
+					}
+					return dir, module, nil
+				}
+			}
+			return "", "", fmt.Errorf("no module path in %s", f.Name())
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("not in a module")
+		}
+		dir = parent
+	}
+}
+
+// PackageFiles reads the Go files of the package in dir that are built with
+// the build tags, test files excluded
+func PackageFiles(dir string, tags []string) (map[string][]byte, error) {
+	ctxt := build.Default
+	ctxt.BuildTags = tags
+	pkg, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string][]byte{}
+	for _, name := range pkg.GoFiles {
+		b, err := ioutil.ReadFile(filepath.Join(pkg.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = b
+	}
+	return ret, nil
+}
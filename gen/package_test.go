@@ -0,0 +1,124 @@
+package gen
+
+import (
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var packageTest = map[string]string{
+	"model.go": `package model
+
+type User struct {
+	Name string
+}
+
+func main() {}
+`,
+	"store.go": `package model
+
+import "fmt"
+
+func main() {
+	fmt.Println("store")
+}
+`,
+}
+
+func TestPackage(t *testing.T) {
+	files := map[string][]byte{}
+	var names []string
+	for name, code := range packageTest {
+		files[name] = []byte(code)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := ""
+	for _, name := range names {
+		fmtBytes, err := format.Source([]byte(packageTest[name]))
+		if err != nil {
+			assert.Nil(t, err, "Formating error on: "+name)
+			return
+		}
+		want += "-- " + name + " --\n" + string(fmtBytes)
+	}
+	file, err := GeneratePackage(files, "main", true, Options{})
+	if err != nil {
+		assert.Nil(t, err, "Could not generate package")
+		return
+	}
+	runGenerator(t, file, want)
+}
+
+func TestPackageUnsupported(t *testing.T) {
+	files := map[string][]byte{
+		"a.go": []byte("package p\n"),
+		"b.go": []byte("package p\n\nvar c = 1i\n"),
+	}
+	_, err := GeneratePackage(files, "main", false, Options{})
+	assert.EqualError(t, err, "b.go: Cannot parse Imaginary Numbers")
+	_, err = GeneratePackageBytes(files, "main", false, false, Options{})
+	assert.EqualError(t, err, "b.go: Cannot parse Imaginary Numbers")
+	_, err = GeneratePackageFiles(files, "main", false, false, Options{})
+	assert.EqualError(t, err, "b.go: Cannot parse Imaginary Numbers")
+
+	files["b.go"] = []byte("package p\n\nvar c =\n")
+	_, err = GeneratePackage(files, "main", false, Options{})
+	assert.EqualError(t, err, "b.go: 3:9: expected operand, found 'EOF'")
+}
+
+func TestPackageFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	sources := map[string]string{
+		"a.go":      "package p\n",
+		"b.go":      "// +build extra\n\npackage p\n",
+		"a_test.go": "package p\n",
+	}
+	for name, code := range sources {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(code), 0644)
+		if err != nil {
+			assert.Nil(t, err)
+			return
+		}
+	}
+	files, err := PackageFiles(dir, nil)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	files, err = PackageFiles(dir, []string{"extra"})
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	out, err := GeneratePackageFiles(files, "main", false, false, Options{})
+	assert.Nil(t, err)
+	assert.Contains(t, out, "a.go")
+	assert.Contains(t, out, "b.go")
+	assert.Contains(t, string(out["package.go"]), "genFileB()")
+}
+
+func TestTxtar(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "bundle.txtar"))
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	archive := ParseTxtar(b)
+	assert.Len(t, archive.Files, 2)
+	file, err := GeneratePackage(archive.GoFiles(), "main", true, Options{})
+	if err != nil {
+		assert.Nil(t, err, "Could not generate package")
+		return
+	}
+	archive.Comment = nil
+	runGenerator(t, file, string(archive.Format()))
+	assert.Equal(t, archive, ParseTxtar(archive.Format()))
+}
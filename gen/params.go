@@ -0,0 +1,482 @@
+package gen
+
+import (
+	"go/ast"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+type paramKind int
+
+const (
+	textParam paramKind = iota
+	intParam
+	litParam
+	codeParam
+	condParam
+	loopParam
+)
+
+// param is a field of the Params struct, or of a loop item, that the
+// generator reads a difference between the instances from
+type param struct {
+	name string
+	hint string
+	kind paramKind
+	vals []string
+	set  []bool
+	code []jen.Code
+	loop *loop
+	// a derived param is not a field, it is built from base
+	base           *param
+	prefix, suffix string
+	lower          bool
+}
+
+// loop is a slice param whose items are the elements of a run
+type loop struct {
+	typ   string
+	v     string
+	owner []int
+	scope *scope
+}
+
+// scope is a struct of params, v is the variable the generator holds the
+// struct in
+type scope struct {
+	v      string
+	params []*param
+	keys   map[string]*param
+	used   map[string]bool
+	// lower is set when a derived param needs the lowerFirst helper
+	lower *bool
+}
+
+func newScope(v string, lower *bool) *scope {
+	return &scope{v: v, keys: map[string]*param{}, used: map[string]bool{}, lower: lower}
+}
+
+func paramKindOf(u useKind) paramKind {
+	switch u {
+	case intUse:
+		return intParam
+	case litUse:
+		return litParam
+	case codeUse:
+		return codeParam
+	}
+	return textParam
+}
+
+// add returns the param holding vals. Params are shared when their values are
+// the same wherever both of them are set.
+func (s *scope) add(kind paramKind, hint string, vals []string, set []bool) *param {
+	key := strconv.Itoa(int(kind))
+	for i, v := range vals {
+		key += "\x00" + strconv.FormatBool(set[i]) + v
+	}
+	if p, ok := s.keys[key]; ok {
+		return p
+	}
+	for _, p := range s.params {
+		if p.kind != kind || kind == condParam || p.vals == nil {
+			continue
+		}
+		if agrees(p.vals, p.set, vals, set) {
+			return p
+		}
+		if agrees(vals, set, p.vals, p.set) {
+			p.vals, p.set = vals, set
+			return p
+		}
+	}
+	p := &param{hint: hint, kind: kind, vals: vals, set: set}
+	s.keys[key] = p
+	s.params = append(s.params, p)
+	return p
+}
+
+// agrees reports whether a is set with the same value wherever b is set
+func agrees(a []string, aset []bool, b []string, bset []bool) bool {
+	for i := range b {
+		if bset[i] && (!aset[i] || a[i] != b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// reserve returns an unused field name of the scope
+func (s *scope) reserve(hint string) string {
+	if hint == "" {
+		hint = "Value"
+	}
+	return uniqueName(hint, s.used)
+}
+
+// finalize derives text params from shorter ones where possible and names
+// the remaining fields
+func (s *scope) finalize() {
+	var cands []*param
+	for _, p := range s.params {
+		if p.kind == textParam {
+			cands = append(cands, p)
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		return maxLen(cands[i].vals) < maxLen(cands[j].vals)
+	})
+	var bases []*param
+	for _, p := range cands {
+		if !derive(p, bases) {
+			bases = append(bases, p)
+		}
+	}
+	for _, p := range s.params {
+		if p.base == nil && p.name == "" {
+			p.name = s.reserve(p.hint)
+		}
+	}
+}
+
+func maxLen(vals []string) int {
+	m := 0
+	for _, v := range vals {
+		if len(v) > m {
+			m = len(v)
+		}
+	}
+	return m
+}
+
+// derive tries to express p as a constant prefix, the value of one of the
+// bases and a constant suffix. Longer bases are preferred.
+func derive(p *param, bases []*param) bool {
+	for b := len(bases) - 1; b >= 0; b-- {
+		base := bases[b]
+		if !covers(base, p) {
+			continue
+		}
+		for _, lower := range []bool{false, true} {
+			val := func(i int) string {
+				if lower {
+					return lowerFirst(base.vals[i])
+				}
+				return base.vals[i]
+			}
+			i0 := -1
+			for i := range p.vals {
+				if p.set[i] {
+					i0 = i
+					break
+				}
+			}
+			if i0 == -1 {
+				return false
+			}
+			v, bv := p.vals[i0], val(i0)
+			for idx := strings.Index(v, bv); idx != -1; idx = nextIndex(v, bv, idx) {
+				prefix, suffix := v[:idx], v[idx+len(bv):]
+				ok := true
+				for i := range p.vals {
+					if p.set[i] && p.vals[i] != prefix+val(i)+suffix {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					p.base, p.prefix, p.suffix, p.lower = base, prefix, suffix, lower
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func nextIndex(s, sub string, idx int) int {
+	next := strings.Index(s[idx+1:], sub)
+	if next == -1 {
+		return -1
+	}
+	return idx + 1 + next
+}
+
+// covers reports whether base is set, with a value of at least two
+// characters, wherever p is set
+func covers(base, p *param) bool {
+	for i := range p.vals {
+		if p.set[i] && (!base.set[i] || len(base.vals[i]) < 2) {
+			return false
+		}
+	}
+	return true
+}
+
+// ref returns the generator code reading p
+func (s *scope) ref(p *param) jen.Code {
+	if p.base == nil {
+		return jen.Id(s.v).Dot(p.name)
+	}
+	var parts []jen.Code
+	if p.prefix != "" {
+		parts = append(parts, jen.Lit(p.prefix))
+	}
+	base := s.ref(p.base)
+	if p.lower {
+		*s.lower = true
+		base = jen.Id("lowerFirst").Call(base)
+	}
+	parts = append(parts, base)
+	if p.suffix != "" {
+		parts = append(parts, jen.Lit(p.suffix))
+	}
+	return joinCode(parts)
+}
+
+// typ returns the type of the field holding p
+func (p *param) typ() jen.Code {
+	switch p.kind {
+	case intParam:
+		return jen.Int()
+	case codeParam:
+		return jen.Qual(jenImp, "Code")
+	case condParam:
+		return jen.Bool()
+	case loopParam:
+		return jen.Index().Id(p.loop.typ)
+	}
+	return jen.String()
+}
+
+// value returns the value of p for instance i
+func (p *param) value(i int) jen.Code {
+	switch p.kind {
+	case intParam:
+		n, _ := strconv.Atoi(p.vals[i])
+		return jen.Lit(n)
+	case codeParam:
+		return p.code[i]
+	case condParam:
+		return jen.Lit(p.vals[i] == "true")
+	case loopParam:
+		var items []jen.Code
+		for k, owner := range p.loop.owner {
+			if owner == i {
+				items = append(items, p.loop.scope.values(k))
+			}
+		}
+		return jen.Index().Id(p.loop.typ).Values(items...)
+	}
+	return jen.Lit(p.vals[i])
+}
+
+// fields returns the struct fields of the scope
+func (s *scope) fields() []jen.Code {
+	var ret []jen.Code
+	for _, p := range s.params {
+		if p.base == nil {
+			ret = append(ret, jen.Id(p.name).Add(p.typ()))
+		}
+	}
+	return ret
+}
+
+// values returns the struct literal of instance i
+func (s *scope) values(i int) jen.Code {
+	d := jen.Dict{}
+	for _, p := range s.params {
+		if p.base == nil && p.set[i] {
+			d[jen.Id(p.name)] = p.value(i)
+		}
+	}
+	return jen.Values(d)
+}
+
+// useLeaf makes the generator read a leaf from ref
+func (t *template) useLeaf(n ast.Node, u useKind, ref jen.Code) {
+	switch u {
+	case identUse:
+		t.names[n.(*ast.Ident)] = ref
+	case stringUse, intUse:
+		t.substs[n] = jen.Dot("Lit").Call(ref)
+	default:
+		t.substs[n] = jen.Dot("Id").Call(ref)
+	}
+}
+
+// useCode makes the generator add the code held by ref in place of n
+func (t *template) useCode(n ast.Node, ref jen.Code) {
+	switch n.(type) {
+	case *ast.BlockStmt, ast.Expr:
+		t.substs[n] = jen.Dot("Add").Call(ref)
+	default:
+		t.substs[n] = ref
+	}
+}
+
+// codeValue converts n to code that can be held by a jen.Code field
+func (cv *converter) codeValue(n ast.Node) jen.Code {
+	switch t := n.(type) {
+	case *ast.BlockStmt:
+		return jen.Qual(jenImp, "Null").Call().Add(cv.blockStmt(t))
+	case ast.Expr:
+		return jen.Qual(jenImp, "Null").Call().Add(cv.genExpr(t))
+	case ast.Stmt:
+		return cv.stmt(t)
+	case *ast.FuncDecl:
+		return cv.funcDecl(t)
+	case *ast.GenDecl:
+		return cv.genDecl(t)
+	}
+	return jen.Null()
+}
+
+// resolve turns the records of the aligner into the template of a generator
+// for n examples
+func (a *aligner) resolve(cv *converter, n int) *template {
+	t := newTemplate()
+	var lower bool
+	outer := newScope("p", &lower)
+	var loops []*param
+	var uses []func()
+
+	// code is converted before any substitution is registered
+	var codes []*param
+	for _, r := range a.recs {
+		if r, ok := r.(*codeRec); ok {
+			p := &param{hint: describe(first(r.nodes)), kind: codeParam, set: make([]bool, n), code: make([]jen.Code, n)}
+			for i, c := range r.nodes {
+				if c != nil {
+					p.set[i], p.code[i] = true, cv.codeValue(c)
+				}
+			}
+			codes = append(codes, p)
+		}
+	}
+
+	for _, r := range a.recs {
+		switch r := r.(type) {
+		case *leafRec:
+			p := outer.add(paramKindOf(r.use), hint(r), r.vals, r.set)
+			node, u := r.node, r.use
+			uses = append(uses, func() { t.useLeaf(node, u, outer.ref(p)) })
+		case *codeRec:
+			p := codes[0]
+			codes = codes[1:]
+			outer.params = append(outer.params, p)
+			node := first(r.nodes)
+			uses = append(uses, func() { t.useCode(node, outer.ref(p)) })
+		case *planRec:
+			var entries []func() entry
+			for _, e := range r.entries {
+				e := e
+				switch {
+				case e.cond != nil:
+					p := outer.add(condParam, "Has"+describe(e.node), e.cond.vals, e.cond.set)
+					entries = append(entries, func() entry {
+						return entry{node: e.node, cond: outer.ref(p)}
+					})
+				case e.loop != nil:
+					p := outer.addLoop(e.loop, &lower)
+					loops = append(loops, p)
+					for _, lr := range e.loop.leaves {
+						lp := p.loop.scope.add(paramKindOf(lr.use), hint(lr), lr.vals, lr.set)
+						node, u, s := lr.node, lr.use, p.loop.scope
+						uses = append(uses, func() { t.useLeaf(node, u, s.ref(lp)) })
+					}
+					entries = append(entries, func() entry {
+						return entry{node: e.node, rng: outer.ref(p), v: p.loop.v, typ: jen.Id(p.loop.typ)}
+					})
+				default:
+					entries = append(entries, func() entry { return entry{node: e.node} })
+				}
+			}
+			parent := r.parent
+			uses = append(uses, func() {
+				var plan []entry
+				for _, e := range entries {
+					plan = append(plan, e())
+				}
+				t.plans[parent] = plan
+			})
+		}
+	}
+
+	outer.finalize()
+	for _, l := range loops {
+		l.loop.scope.finalize()
+	}
+	for _, u := range uses {
+		u()
+	}
+
+	t.params = []jen.Code{jen.Id("p").Id("Params")}
+	t.args = []jen.Code{jen.Id("p")}
+	t.decls = append(t.decls, jen.Type().Id("Params").Struct(outer.fields()...))
+	for _, l := range loops {
+		t.decls = append(t.decls, jen.Type().Id(l.loop.typ).Struct(l.loop.scope.fields()...))
+	}
+	var examples []jen.Code
+	for i := 0; i < n; i++ {
+		examples = append(examples, outer.values(i))
+	}
+	t.decls = append(t.decls, jen.Var().Id("examples").Op("=").Index().Id("Params").Values(examples...))
+	if lower {
+		t.decls = append(t.decls, genLowerFirst())
+	}
+	t.main = []jen.Code{
+		jen.For(jen.List(jen.Id("_"), jen.Id("p")).Op(":=").Range().Id("examples")).Block(
+			jen.Qual("fmt", "Printf").Call(jen.Lit("%#v\n"), jen.Id("genFile").Call(jen.Id("p"))),
+		),
+	}
+	return t
+}
+
+// hint names the param of a leaf after its first value
+func hint(r *leafRec) string {
+	switch r.use {
+	case intUse:
+		return "N"
+	case litUse:
+		return "Lit"
+	}
+	for i, v := range r.vals {
+		if r.set[i] {
+			return exported(v)
+		}
+	}
+	return ""
+}
+
+// addLoop adds the slice param of a loop
+func (s *scope) addLoop(l *loopRec, lower *bool) *param {
+	name := s.reserve(l.container)
+	p := &param{name: name, kind: loopParam, set: l.set, loop: &loop{
+		typ:   name + "Item",
+		v:     loopVar(name),
+		owner: l.owner,
+		scope: newScope(loopVar(name), lower),
+	}}
+	s.params = append(s.params, p)
+	return p
+}
+
+// loopVar names the variable holding a loop item
+func loopVar(name string) string {
+	v := lowerFirst(name)
+	if len(v) > 1 && strings.HasSuffix(v, "s") {
+		v = v[:len(v)-1]
+	}
+	return v
+}
+
+func genLowerFirst() jen.Code {
+	return jen.Func().Id("lowerFirst").Params(jen.Id("s").String()).String().Block(
+		jen.If(jen.Id("s").Op("==").Lit("")).Block(jen.Return().Id("s")),
+		jen.Return().Qual("strings", "ToLower").Call(jen.Id("s").Index(jen.Empty(), jen.Lit(1))).Op("+").Id("s").Index(jen.Lit(1), jen.Empty()),
+	)
+}
@@ -0,0 +1,123 @@
+package gen
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+)
+
+// parse parses the source of the converter. With partial the top level
+// declarations with syntax errors are left out, with a warning for each,
+// instead of failing.
+func (cv *converter) parse(partial bool) *ast.File {
+	if !partial {
+		return parseFile(cv.src)
+	}
+	f, warnings := parsePartial(cv.src)
+	cv.warnings = append(cv.warnings, warnings...)
+	return f
+}
+
+// parsePartial parses src without the top level declarations that have
+// syntax errors and returns a warning with the first error of each of them. Declarations
+// start at a line beginning with their keyword, or at the comment above it,
+// and are blanked out so that the positions in the file stay those of src. It
+// panics when the package clause does not parse, like parseFile.
+func parsePartial(src []byte) (*ast.File, []Warning) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err == nil {
+		return f, nil
+	}
+	chunks := declChunks(src)
+	var warnings []Warning
+	keep := []bool{true}
+	for i := 1; i < len(chunks); i++ {
+		only := make([]bool, len(chunks))
+		only[0], only[i] = true, true
+		_, err := parser.ParseFile(token.NewFileSet(), "", blankChunks(src, chunks, only), parser.AllErrors)
+		list, ok := err.(scanner.ErrorList)
+		if !ok || len(list) == 0 {
+			keep = append(keep, true)
+			continue
+		}
+		keep = append(keep, false)
+		// errors at the end of the file, like a missing brace, are
+		// reported at the start of the declaration
+		offset := list[0].Pos.Offset
+		if (i+1 < len(chunks) && offset >= chunks[i+1]) || offset >= len(src) {
+			offset = chunks[i]
+		}
+		warnings = append(warnings, Warning{
+			Position: offsetPosition(src, offset),
+			Node:     "BadDecl",
+			Category: "syntax",
+			Message:  list[0].Msg + ", the declaration is skipped",
+		})
+	}
+	f, err = parser.ParseFile(token.NewFileSet(), "", blankChunks(src, chunks, keep), parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	return f, warnings
+}
+
+// declChunks returns the offsets the top level declarations of src start
+// at, after the first chunk holding the package clause
+func declChunks(src []byte) []int {
+	ret := []int{0}
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	offset, comment := 0, -1
+	for _, line := range lines {
+		switch {
+		case bytes.HasPrefix(line, []byte("//")):
+			if comment == -1 {
+				comment = offset
+			}
+		case startsDecl(line):
+			start := offset
+			if comment != -1 {
+				start = comment
+			}
+			if start > 0 {
+				ret = append(ret, start)
+			}
+			comment = -1
+		default:
+			comment = -1
+		}
+		offset += len(line)
+	}
+	return ret
+}
+
+func startsDecl(line []byte) bool {
+	for _, kw := range []string{"func", "type", "var", "const", "import"} {
+		if bytes.HasPrefix(line, []byte(kw)) && len(line) > len(kw) && (line[len(kw)] == ' ' || line[len(kw)] == '(' || line[len(kw)] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+// blankChunks returns src with the chunks that are not kept replaced by
+// spaces, keeping the line breaks
+func blankChunks(src []byte, chunks []int, keep []bool) []byte {
+	ret := append([]byte{}, src...)
+	for i, start := range chunks {
+		if keep[i] {
+			continue
+		}
+		end := len(src)
+		if i+1 < len(chunks) {
+			end = chunks[i+1]
+		}
+		for j := start; j < end; j++ {
+			if ret[j] != '\n' {
+				ret[j] = ' '
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,58 @@
+package gen
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/aloder/tojen/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartial(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func a() int {
+	x := 1 +
+	return x
+}
+
+// b is broken
+func b() {
+	if true {
+}
+
+var c = 3
+
+func main() {
+	fmt.Println(c)
+}
+`
+	assert.Panics(t, func() { GenerateFile([]byte(src), "main", true) })
+
+	r := &Report{}
+	out, err := GenerateFileBytesWith([]byte(src), "main", true, false, Options{Partial: true, Report: r})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	ret, err := run.Exec(string(out))
+	if err != nil {
+		assert.Nil(t, err, "Could not execute rendered test file: \n"+string(out))
+		return
+	}
+	want, _ := format.Source([]byte("package main\n\nimport \"fmt\"\n\nvar c = 3\n\nfunc main() {\n\tfmt.Println(c)\n}\n"))
+	assert.Equal(t, string(want), *ret)
+
+	var warnings []string
+	for _, w := range r.Files[0].Warnings {
+		warnings = append(warnings, w.String())
+	}
+	assert.Equal(t, []string{
+		"7:2: syntax: expected ';', found 'return', the declaration is skipped",
+		"10:1: syntax: expected ';', found 'EOF', the declaration is skipped",
+	}, warnings)
+
+	assert.Panics(t, func() { GenerateFileWith([]byte("pakage main\n"), "main", false, Options{Partial: true}) })
+}
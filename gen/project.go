@@ -0,0 +1,86 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aloder/tojen/run"
+	"github.com/dave/jennifer/jen"
+)
+
+// InitProject writes a generator module for the source file at srcPath to
+// dir: a go.mod requiring jennifer, the generator and a main function writing
+// the generated code to the path given as its argument. It returns the
+// go:generate directive writing the generated code to target from the package
+// of the source, or to name_gen.go next to the source file name.go when
+// target is empty. Code the converter does not support is an error rather
+// than a panic.
+func InitProject(srcPath, dir, target string, opts Options) (directive string, err error) {
+	defer recoverConversion(&err)
+	src, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+	file := GenerateFileWith(src, "main", false, opts)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := file.Save(filepath.Join(dir, "gen.go")); err != nil {
+		return "", err
+	}
+	if err := genProjectMain().Save(filepath.Join(dir, "main.go")); err != nil {
+		return "", err
+	}
+	if err := run.Module(dir, filepath.Base(dir)); err != nil {
+		return "", err
+	}
+	absSrc, err := filepath.Abs(srcPath)
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	toDir, err := filepath.Rel(filepath.Dir(absSrc), absDir)
+	if err != nil {
+		return "", err
+	}
+	if target == "" {
+		target = strings.TrimSuffix(srcPath, ".go") + "_gen.go"
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	if absTarget == absSrc {
+		return "", errors.New("the generated code would overwrite the source")
+	}
+	// go run -C runs the generator in its directory
+	toTarget, err := filepath.Rel(absDir, absTarget)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("//go:generate go run -C %s . %s", filepath.ToSlash(toDir), filepath.ToSlash(toTarget)), nil
+}
+
+func genProjectMain() *jen.File {
+	file := jen.NewFile("main")
+	file.Comment("main writes the generated code to the path given as the argument")
+	file.Func().Id("main").Params().Block(
+		jen.If(jen.Len(jen.Qual("os", "Args")).Op("!=").Lit(2)).Block(
+			jen.Qual("fmt", "Println").Call(jen.Lit("usage: go run . [output path]")),
+			jen.Qual("os", "Exit").Call(jen.Lit(1)),
+		),
+		jen.Id("err").Op(":=").Id("genFile").Call().Dot("Save").Call(jen.Qual("os", "Args").Index(jen.Lit(1))),
+		jen.If(jen.Id("err").Op("!=").Nil()).Block(
+			jen.Qual("fmt", "Println").Call(jen.Id("err")),
+			jen.Qual("os", "Exit").Call(jen.Lit(1)),
+		),
+	)
+	return file
+}
@@ -0,0 +1,50 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	src := "package model\n\ntype User struct {\n\tName string\n}\n"
+	srcPath := filepath.Join(dir, "model", "model.go")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(srcPath), 0755))
+	assert.Nil(t, ioutil.WriteFile(srcPath, []byte(src), 0644))
+
+	_, err = InitProject(srcPath, filepath.Join(dir, "tools", "modelgen"), srcPath, Options{})
+	assert.NotNil(t, err)
+	directive, err := InitProject(srcPath, filepath.Join(dir, "tools", "modelgen"), "", Options{})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	assert.Equal(t, "//go:generate go run -C ../tools/modelgen . ../../model/model_gen.go", directive)
+
+	// go generate runs the directive from the source, which it leaves alone
+	withDirective := src + "\n" + directive + "\n"
+	assert.Nil(t, ioutil.WriteFile(srcPath, []byte(withDirective), 0644))
+	cmd := exec.Command("go", "generate", "model.go")
+	cmd.Dir = filepath.Dir(srcPath)
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		assert.Nil(t, err, string(b))
+		return
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "model", "model_gen.go"))
+	assert.Nil(t, err)
+	assert.Equal(t, src, string(b))
+	b, err = ioutil.ReadFile(srcPath)
+	assert.Nil(t, err)
+	assert.Equal(t, withDirective, string(b))
+}
@@ -0,0 +1,216 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Report describes a conversion, see Options.Report
+type Report struct {
+	// Output is the path the generator was written to, set by the caller
+	Output string        `json:"output,omitempty"`
+	Files  []*FileReport `json:"files"`
+}
+
+// FileReport describes the conversion of one source file
+type FileReport struct {
+	// Name is the name of the source file, empty for a single file
+	Name string `json:"name,omitempty"`
+	// Generator is the function returning the generated file
+	Generator   string         `json:"generator"`
+	Decls       []DeclReport   `json:"decls"`
+	Imports     []ImportReport `json:"imports"`
+	AnonImports []string       `json:"anonImports,omitempty"`
+	Warnings    []Warning      `json:"warnings,omitempty"`
+}
+
+// DeclReport describes the generator function of a declaration
+type DeclReport struct {
+	Generator string `json:"generator"`
+	// Kind is const, var, type, func, method or import
+	Kind  string   `json:"kind"`
+	Names []string `json:"names,omitempty"`
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// ImportReport describes an import. How is resolved when the name of the
+// import is given or is its path, and guessed when it is taken from the path.
+type ImportReport struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	How  string `json:"how"`
+}
+
+// Warning is a construct of the source the generator drops or approximates
+type Warning struct {
+	Position Position `json:"position"`
+	// Node is the type of the syntax node, like BasicLit
+	Node string `json:"node"`
+	// Category is one of comment, generics, import, literal, syntax, tag,
+	// unsupported and verbatim
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", w.Position.Line, w.Position.Column, w.Category, w.Message)
+}
+
+// Warnings returns the warnings of every file, prefixed with the names of the
+// files of a package
+func (r *Report) Warnings() []string {
+	var ret []string
+	for _, f := range r.Files {
+		for _, w := range f.Warnings {
+			if f.Name != "" {
+				ret = append(ret, f.Name+":"+w.String())
+			} else {
+				ret = append(ret, w.String())
+			}
+		}
+	}
+	return ret
+}
+
+// Position is a position in a source file, lines and columns count from 1
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// file adds the report of a file, it returns nil when r is nil
+func (r *Report) file(name string) *FileReport {
+	if r == nil {
+		return nil
+	}
+	f := &FileReport{Name: name, Decls: []DeclReport{}, Imports: []ImportReport{}}
+	r.Files = append(r.Files, f)
+	return f
+}
+
+// position returns the position of pos in the source of the converter, which
+// is parsed as the only file of its file set
+func (cv *converter) position(pos token.Pos) Position {
+	return offsetPosition(cv.src, int(pos)-1)
+}
+
+// offsetPosition returns the position of the offset in src
+func offsetPosition(src []byte, offset int) Position {
+	if offset < 0 || offset > len(src) {
+		return Position{}
+	}
+	line := 1 + strings.Count(string(src[:offset]), "\n")
+	col := offset + 1
+	if i := strings.LastIndex(string(src[:offset]), "\n"); i != -1 {
+		col = offset - i
+	}
+	return Position{Offset: offset, Line: line, Column: col}
+}
+
+// warn records that the converter drops or approximates the node
+func (cv *converter) warn(n ast.Node, category, format string, args ...interface{}) {
+	node := fmt.Sprintf("%T", n)
+	w := Warning{
+		Position: cv.position(n.Pos()),
+		Node:     node[strings.LastIndex(node, ".")+1:],
+		Category: category,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	// templates may convert a node more than once
+	for _, have := range cv.warnings {
+		if have == w {
+			return
+		}
+	}
+	cv.warnings = append(cv.warnings, w)
+}
+
+// reportDecl adds the generator function name of the declaration to the
+// report of the converter
+func (cv *converter) reportDecl(d ast.Decl, name string) {
+	if cv.report == nil {
+		return
+	}
+	r := DeclReport{Generator: name, Names: declNames(d), Start: cv.position(d.Pos()), End: cv.position(d.End())}
+	switch t := d.(type) {
+	case *ast.FuncDecl:
+		r.Kind = "func"
+		if t.Recv != nil && len(t.Recv.List) > 0 {
+			r.Kind = "method"
+			r.Names = []string{recvName(t.Recv.List[0].Type) + "." + t.Name.Name}
+		}
+	case *ast.GenDecl:
+		r.Kind = t.Tok.String()
+	}
+	cv.report.Decls = append(cv.report.Decls, r)
+}
+
+// strictError returns an error listing the warnings of the report, if any
+func strictError(r *Report) error {
+	if r == nil {
+		return nil
+	}
+	warnings := r.Warnings()
+	if len(warnings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d warnings in strict mode:\n%s", len(warnings), strings.Join(warnings, "\n"))
+}
+
+// guessedName returns the name imports gives a package imported without a
+// name when it is not its path
+func guessedName(path string) (string, bool) {
+	if i := strings.Index(path, "/"); i != -1 {
+		return path[i+1:], true
+	}
+	return path, false
+}
+
+// warnFile adds the warnings about the file as a whole: the comments, which
+// are not generated unless the template keeps them, and the names of imports
+// that are guessed
+func (cv *converter) warnFile(f *ast.File) {
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if name, ok := guessedName(path); ok && imp.Name == nil {
+			cv.warn(imp, "import", "the name of %s is guessed as %s", path, name)
+		}
+	}
+	for _, c := range f.Comments {
+		if cv.tmpl != nil && cv.tmpl.comments[c] {
+			continue
+		}
+		if !skipped(c) || len(c.List) > 1 {
+			cv.warn(c, "comment", "comment is discarded")
+		}
+	}
+}
+
+// reportFile adds the imports and warnings of the file to the report of the
+// converter
+func (cv *converter) reportFile(f *ast.File, genName string) {
+	if cv.report == nil {
+		return
+	}
+	cv.report.Generator = genName
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil && imp.Name.Name == "_" {
+			cv.report.AnonImports = append(cv.report.AnonImports, path)
+			continue
+		}
+		r := ImportReport{Path: path, Name: path, How: "resolved"}
+		if imp.Name != nil {
+			r.Name = imp.Name.Name
+		} else if name, ok := guessedName(path); ok {
+			r.Name, r.How = name, "guessed"
+		}
+		cv.report.Imports = append(cv.report.Imports, r)
+	}
+	cv.report.Warnings = cv.warnings
+}
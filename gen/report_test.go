@@ -0,0 +1,83 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	_ "embed"
+	"github.com/x/y"
+)
+
+type User struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+func (u User) Save() int {
+	return 0x10
+}
+
+func main() {
+	fmt.Println(y.Z)
+}
+`
+	r := &Report{}
+	_, err := GenerateFileBytesWith([]byte(src), "main", false, false, Options{Report: r})
+	if err != nil || len(r.Files) != 1 {
+		assert.Nil(t, err)
+		assert.Len(t, r.Files, 1)
+		return
+	}
+	f := r.Files[0]
+	assert.Equal(t, "genFile", f.Generator)
+	assert.Equal(t, []DeclReport{
+		{Generator: "genDeclAt15", Kind: "import", Start: Position{14, 3, 1}, End: Position{60, 7, 2}},
+		{Generator: "genDeclAt63", Kind: "type", Names: []string{"User"}, Start: Position{62, 9, 1}, End: Position{109, 11, 2}},
+		{Generator: "genFuncSave", Kind: "method", Names: []string{"User.Save"}, Start: Position{111, 13, 1}, End: Position{152, 15, 2}},
+		{Generator: "genFuncmain", Kind: "func", Names: []string{"main"}, Start: Position{154, 17, 1}, End: Position{187, 19, 2}},
+	}, f.Decls)
+	assert.Equal(t, []ImportReport{
+		{Path: "fmt", Name: "fmt", How: "resolved"},
+		{Path: "github.com/x/y", Name: "x/y", How: "guessed"},
+	}, f.Imports)
+	assert.Equal(t, []string{"embed"}, f.AnonImports)
+	assert.Equal(t, []string{
+		"6:2: import: the name of github.com/x/y is guessed as x/y",
+		"10:14: tag: struct tag `json:\"name\"` is dropped",
+		"14:9: literal: integer literal 0x10 is dropped, only decimal int32 values are supported",
+	}, r.Warnings())
+
+	r = &Report{}
+	_, err = GeneratePackageBytes(map[string][]byte{"a.go": []byte("package p\n\nvar A = 1\n"), "b.go": []byte("package p\n\nvar B = 2\n")}, "main", false, false, Options{Report: r})
+	assert.Nil(t, err)
+	if assert.Len(t, r.Files, 2) {
+		assert.Equal(t, "a.go", r.Files[0].Name)
+		assert.Equal(t, "genFileA", r.Files[0].Generator)
+		assert.Equal(t, "genFileB", r.Files[1].Generator)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	files := map[string][]byte{"": []byte("package main\n\n// T is a type\ntype T struct {\n\tA int `json:\"a\"`\n}\n\n//tojen:skip\nvar x = 1\n")}
+	_, err := BatchEntry{}.Generate(files)
+	assert.Nil(t, err)
+
+	_, err = BatchEntry{Strict: true}.Generate(files)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "2 warnings in strict mode:\n3:1: comment: comment is discarded\n5:8: tag: struct tag `json:\"a\"` is dropped", err.Error())
+	}
+}
+
+func TestReportKeptComments(t *testing.T) {
+	src := "package main\n\n// User is a user\ntype User struct {\n\tName string // the name\n}\n"
+	r := &Report{}
+	_, err := GenerateFileBytesWith([]byte(src), "main", false, false, Options{Fields: []string{"User"}, Report: r})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3:1: comment: comment is discarded"}, r.Warnings())
+}
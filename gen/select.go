@@ -0,0 +1,161 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// skipDirective is the comment leaving the declaration or spec it documents
+// out of the generator
+const skipDirective = "//tojen:skip"
+
+// declKinds maps the kinds of Options.Kinds to their tokens, func stands for
+// functions and methods
+var declKinds = map[string]token.Token{
+	"const": token.CONST,
+	"var":   token.VAR,
+	"type":  token.TYPE,
+	"func":  token.FUNC,
+}
+
+// Validate returns an error for options that can not be used together or
+// name unknown kinds or naming strategies
+func (o Options) Validate() error {
+	if (len(o.Fields) > 0 && len(o.Interfaces) > 0) || (len(o.Fields)+len(o.Interfaces) > 0 && len(o.Enums) > 0) {
+		return fmt.Errorf("only one of fields, interfaces and enums can be used")
+	}
+	if o.Naming != "" && o.Naming != "position" && o.Naming != "name" {
+		return fmt.Errorf("unknown naming %q, want position or name", o.Naming)
+	}
+	for _, k := range o.Kinds {
+		if _, ok := declKinds[k]; !ok {
+			return fmt.Errorf("unknown declaration kind %q, want const, var, type or func", k)
+		}
+	}
+	return nil
+}
+
+// selectDecls drops the declarations of f the options leave out and those
+// with the skip directive. Specs of grouped declarations are selected one by
+// one and a declaration without specs left is dropped, except for const
+// groups with implicit values which are kept whole when any spec is selected.
+func selectDecls(f *ast.File, opts Options) {
+	only := nameSet(opts.Only)
+	exclude := nameSet(opts.Exclude)
+	kinds := map[token.Token]bool{}
+	for _, k := range opts.Kinds {
+		kinds[declKinds[k]] = true
+	}
+	keep := func(names []string, doc *ast.CommentGroup) bool {
+		if skipped(doc) {
+			return false
+		}
+		for _, name := range names {
+			if exclude[name] {
+				return false
+			}
+		}
+		if len(only) == 0 {
+			return true
+		}
+		for _, name := range names {
+			if only[name] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var decls []ast.Decl
+	for _, d := range f.Decls {
+		switch t := d.(type) {
+		case *ast.FuncDecl:
+			if len(kinds) > 0 && !kinds[token.FUNC] {
+				continue
+			}
+			names := []string{t.Name.Name}
+			if t.Recv != nil && len(t.Recv.List) > 0 {
+				names = append(names, recvName(t.Recv.List[0].Type)+"."+t.Name.Name)
+			}
+			if keep(names, t.Doc) {
+				decls = append(decls, d)
+			}
+		case *ast.GenDecl:
+			if t.Tok == token.IMPORT {
+				// imports are not generated on their own, the converter
+				// reads them from the file
+				if len(kinds) == 0 && len(only) == 0 {
+					decls = append(decls, d)
+				}
+				continue
+			}
+			if (len(kinds) > 0 && !kinds[t.Tok]) || skipped(t.Doc) {
+				continue
+			}
+			var specs []ast.Spec
+			for _, spec := range t.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if keep([]string{s.Name.Name}, s.Doc) {
+						specs = append(specs, spec)
+					}
+				case *ast.ValueSpec:
+					var names []string
+					for _, n := range s.Names {
+						names = append(names, n.Name)
+					}
+					if keep(names, s.Doc) {
+						specs = append(specs, spec)
+					}
+				}
+			}
+			if len(specs) == len(t.Specs) || (len(specs) > 0 && implicitValues(t)) {
+				// the implicit values of a const group, like iota, depend
+				// on the specs before, so the group is kept whole
+				decls = append(decls, d)
+			} else if len(specs) > 0 {
+				g := *t
+				g.Specs = specs
+				decls = append(decls, &g)
+			}
+		}
+	}
+	f.Decls = decls
+}
+
+// implicitValues reports whether a spec of a const group repeats the values
+// of the one before
+func implicitValues(g *ast.GenDecl) bool {
+	if g.Tok != token.CONST {
+		return false
+	}
+	for _, spec := range g.Specs {
+		if s, ok := spec.(*ast.ValueSpec); ok && len(s.Values) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// skipped reports whether the comment group holds the skip directive
+func skipped(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == skipDirective {
+			return true
+		}
+	}
+	return false
+}
+
+func nameSet(names []string) map[string]bool {
+	ret := map[string]bool{}
+	for _, n := range names {
+		ret[n] = true
+	}
+	return ret
+}
@@ -0,0 +1,85 @@
+package gen
+
+import (
+	"go/ast"
+	"go/format"
+	"testing"
+
+	"github.com/aloder/tojen/run"
+	"github.com/stretchr/testify/assert"
+)
+
+const selectSource = `package main
+
+import "fmt"
+
+type User struct {
+	Name string
+}
+
+func (u *User) Save() error {
+	return nil
+}
+
+//tojen:skip
+func helper() {}
+
+const (
+	A = iota
+	B
+)
+
+var (
+	x = 1
+	//tojen:skip
+	y = 2
+)
+
+func main() {
+	fmt.Println(x)
+}
+`
+
+func TestSelectDecls(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Opts  Options
+		Names []string
+	}{
+		{"Directive", Options{}, []string{"User", "User.Save", "A", "B", "x", "main"}},
+		{"Only", Options{Only: []string{"User", "Save"}}, []string{"User", "User.Save"}},
+		{"Method", Options{Only: []string{"User.Save"}}, []string{"User.Save"}},
+		{"Kinds", Options{Kinds: []string{"var", "func"}}, []string{"User.Save", "x", "main"}},
+		{"Exclude", Options{Exclude: []string{"User", "x"}}, []string{"User.Save", "A", "B", "main"}},
+		{"ImplicitConst", Options{Exclude: []string{"B"}, Kinds: []string{"const"}}, []string{"A", "B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			f := parseFile([]byte(selectSource))
+			selectDecls(f, tt.Opts)
+			var names []string
+			for _, d := range f.Decls {
+				if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv != nil {
+					names = append(names, recvName(fd.Recv.List[0].Type)+"."+fd.Name.Name)
+				}
+				names = append(names, declNames(d)...)
+			}
+			assert.Equal(t, tt.Names, names)
+		})
+	}
+
+	out, err := GenerateFileBytesWith([]byte(selectSource), "main", true, false, Options{Only: []string{"x", "main"}})
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	ret, err := run.Exec(string(out))
+	if err != nil {
+		assert.Nil(t, err, "Could not execute rendered test file: \n"+string(out))
+		return
+	}
+	want, _ := format.Source([]byte("package main\n\nimport \"fmt\"\n\nvar (\n\tx = 1\n)\n\nfunc main() {\n\tfmt.Println(x)\n}\n"))
+	assert.Equal(t, string(want), *ret)
+
+	assert.NotNil(t, Options{Kinds: []string{"method"}}.Validate())
+}
@@ -0,0 +1,156 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"runtime"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// GenerateExpr returns the jennifer code of a Go expression and the warnings
+// of its conversion. Package names are not known to a snippet, so selectors
+// on them are generated with Id. Only Lenient and Report of the options are
+// used.
+func GenerateExpr(src string, opts Options) (code jen.Code, warnings []Warning, err error) {
+	defer recoverConversion(&err)
+	e, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	cv := snippetConverter([]byte(src), opts)
+	code = jen.Id("jen").Add(cv.genExpr(e))
+	return code, cv.snippetWarnings(0), nil
+}
+
+// GenerateStmts returns the jennifer code of every statement of a list of Go
+// statements and the warnings of their conversion, see GenerateExpr
+func GenerateStmts(src string, opts Options) (codes []jen.Code, warnings []Warning, err error) {
+	defer recoverConversion(&err)
+	fset := token.NewFileSet()
+	wrapped := "package p; func _() {\n" + src + "\n}"
+	f, err := parser.ParseFile(fset, "", wrapped, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	cv := snippetConverter([]byte(wrapped), opts)
+	for _, s := range f.Decls[0].(*ast.FuncDecl).Body.List {
+		codes = append(codes, cv.stmt(s))
+	}
+	return codes, cv.snippetWarnings(len(wrapped) - len(src) - len("\n}")), nil
+}
+
+// GenerateLines returns the jennifer code of the declarations of a file on
+// the lines from to to, or if the lines are inside of a declaration the
+// statements on them in the block holding them, and the warnings of their
+// conversion, see GenerateExpr
+func GenerateLines(src []byte, from, to int, opts Options) (codes []jen.Code, warnings []Warning, err error) {
+	defer recoverConversion(&err)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	inside := func(n ast.Node) bool {
+		return fset.Position(n.Pos()).Line >= from && fset.Position(n.End()).Line <= to
+	}
+	cv := snippetConverter(src, opts)
+	cv.paths, _ = imports(f.Imports)
+	for _, d := range f.Decls {
+		if !inside(d) {
+			continue
+		}
+		switch t := d.(type) {
+		case *ast.FuncDecl:
+			codes = append(codes, cv.funcDecl(t))
+		case *ast.GenDecl:
+			if t.Tok != token.IMPORT {
+				codes = append(codes, cv.genDecl(t))
+			}
+		}
+	}
+	if len(codes) > 0 {
+		return codes, cv.snippetWarnings(0), nil
+	}
+
+	var list []ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || fset.Position(n.End()).Line < from || fset.Position(n.Pos()).Line > to {
+			return false
+		}
+		var stmts []ast.Stmt
+		switch t := n.(type) {
+		case *ast.BlockStmt:
+			stmts = t.List
+		case *ast.CaseClause:
+			stmts = t.Body
+		case *ast.CommClause:
+			stmts = t.Body
+		}
+		var in []ast.Stmt
+		for _, s := range stmts {
+			if inside(s) {
+				in = append(in, s)
+			}
+		}
+		// the outermost block with statements on the lines holds them
+		if len(in) > 0 && list == nil {
+			list = in
+		}
+		return true
+	})
+	if len(list) == 0 {
+		return nil, nil, fmt.Errorf("no declaration or statement on lines %d to %d", from, to)
+	}
+	for _, s := range list {
+		codes = append(codes, cv.stmt(s))
+	}
+	return codes, cv.snippetWarnings(0), nil
+}
+
+// snippetConverter returns a converter of the snippet src with the options
+func snippetConverter(src []byte, opts Options) *converter {
+	cv := newConverter()
+	cv.src, cv.report, cv.lenient = src, opts.Report.file(""), opts.Lenient
+	return cv
+}
+
+// snippetWarnings returns the warnings of the converter at their positions
+// in the snippet, which starts at offset in the parsed source, and adds them
+// to the report
+func (cv *converter) snippetWarnings(offset int) []Warning {
+	snippet := cv.src[offset:]
+	var ret []Warning
+	for _, w := range cv.warnings {
+		w.Position = offsetPosition(snippet, w.Position.Offset-offset)
+		ret = append(ret, w)
+	}
+	if cv.report != nil {
+		cv.report.Warnings = ret
+	}
+	return ret
+}
+
+// SnippetCode returns the jennifer code of a snippet, one line each
+func SnippetCode(codes ...jen.Code) string {
+	var lines []string
+	for _, c := range codes {
+		lines = append(lines, fmt.Sprintf("%#v", c))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// recoverConversion turns the panics of the converter on code it does not
+// support into errors. A runtime error is a bug of the converter and panics
+// again.
+func recoverConversion(err *error) {
+	if r := recover(); r != nil {
+		if _, ok := r.(runtime.Error); ok {
+			panic(r)
+		}
+		*err = fmt.Errorf("%v", r)
+	}
+}
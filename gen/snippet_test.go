@@ -0,0 +1,81 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateExpr(t *testing.T) {
+	code, warnings, err := GenerateExpr("a[i] + f(x)", Options{})
+	assert.Nil(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, `jen.Id("a").Index(jen.Id("i")).Op("+").Id("f").Call(jen.Id("x"))`, SnippetCode(code))
+
+	_, _, err = GenerateExpr("a +", Options{})
+	assert.NotNil(t, err)
+
+	// the literal is dropped with a warning, or kept verbatim when lenient
+	src := `fmt.Sprintf("%d", 0x10)`
+	_, warnings, err = GenerateExpr(src, Options{})
+	assert.Nil(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, "1:19: literal: integer literal 0x10 is dropped, only decimal int32 values are supported", warnings[0].String())
+	}
+	r := &Report{}
+	code, warnings, err = GenerateExpr(src, Options{Lenient: true, Report: r})
+	assert.Nil(t, err)
+	assert.Equal(t, `jen.Id("fmt").Dot("Sprintf").Call(jen.Lit("%d"), jen.Id("0x10"))`, SnippetCode(code))
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, r.Warnings(), []string{warnings[0].String()})
+}
+
+func TestGenerateStmts(t *testing.T) {
+	codes, warnings, err := GenerateStmts("if err != nil { return err }\nx++", Options{})
+	assert.Nil(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, `jen.If(jen.Id("err").Op("!=").Id("nil")).Block(jen.Return().Id("err"))
+jen.Id("x").Op("++")`, SnippetCode(codes...))
+
+	// positions are in the statements
+	_, warnings, err = GenerateStmts("x := 1\ny := 0x10", Options{})
+	assert.Nil(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, 2, warnings[0].Position.Line)
+		assert.Equal(t, 6, warnings[0].Position.Column)
+	}
+}
+
+func TestGenerateLines(t *testing.T) {
+	src := []byte(`package main
+
+import "fmt"
+
+func main() {
+	s := "hi"
+	if s != "" {
+		fmt.Println(s)
+	}
+}
+`)
+	tests := []struct {
+		Name     string
+		From, To int
+		Code     string
+	}{
+		{"Declaration", 4, 10, `jen.Func().Id("main").Params().Block(jen.Id("s").Op(":=").Lit("hi"), jen.If(jen.Id("s").Op("!=").Lit("")).Block(jen.Qual("fmt", "Println").Call(jen.Id("s"))))`},
+		{"Statements", 6, 9, `jen.Id("s").Op(":=").Lit("hi")
+jen.If(jen.Id("s").Op("!=").Lit("")).Block(jen.Qual("fmt", "Println").Call(jen.Id("s")))`},
+		{"Nested", 8, 8, `jen.Qual("fmt", "Println").Call(jen.Id("s"))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			codes, _, err := GenerateLines(src, tt.From, tt.To, Options{})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Code, SnippetCode(codes...))
+		})
+	}
+
+	_, _, err := GenerateLines(src, 5, 5, Options{})
+	assert.NotNil(t, err)
+}
@@ -8,134 +8,142 @@ import (
 	"github.com/dave/jennifer/jen"
 )
 
-func stmt(s ast.Stmt) jen.Code {
+func (cv *converter) stmt(s ast.Stmt) (ret jen.Code) {
+	if cv.lenient {
+		defer cv.recoverVerbatim(s, &ret, true)
+	}
+	if c, ok := cv.subst(s); ok {
+		return c
+	}
 	switch t := s.(type) {
 	case *ast.BadStmt:
 	case *ast.DeclStmt:
-		return declStmt(t)
+		return cv.declStmt(t)
 	case *ast.GoStmt:
-		return goStmt(t)
+		return cv.goStmt(t)
 	case *ast.DeferStmt:
-		return deferStmt(t)
+		return cv.deferStmt(t)
 	case *ast.EmptyStmt:
 		return emptyStmt(t)
 	case *ast.LabeledStmt:
-		return labeledStmt(t)
+		return cv.labeledStmt(t)
 	case *ast.ExprStmt:
-		return exprStmt(t)
+		return cv.exprStmt(t)
 	case *ast.SendStmt:
-		return sendStmt(t)
+		return cv.sendStmt(t)
 	case *ast.IncDecStmt:
-		return incDecStmt(t)
+		return cv.incDecStmt(t)
 	case *ast.AssignStmt:
-		return assignStmt(t)
+		return cv.assignStmt(t)
 	case *ast.ReturnStmt:
-		return returnStmt(t)
+		return cv.returnStmt(t)
 	case *ast.BranchStmt:
-		return branchStmt(t)
+		return cv.branchStmt(t)
 	case *ast.BlockStmt:
-		return blockStmt(t)
+		return cv.blockStmt(t)
 	case *ast.IfStmt:
-		return ifStmt(t)
+		return cv.ifStmt(t)
 	case *ast.CaseClause:
-		return caseClause(t)
+		return cv.caseClause(t)
 	case *ast.SwitchStmt:
-		return switchStmt(t)
+		return cv.switchStmt(t)
 	case *ast.TypeSwitchStmt:
-		return typeSwitchStmt(t)
+		return cv.typeSwitchStmt(t)
 	case *ast.CommClause:
-		return commClause(t)
+		return cv.commClause(t)
 	case *ast.SelectStmt:
-		return selectStmt(t)
+		return cv.selectStmt(t)
 	case *ast.ForStmt:
-		return forStmt(t)
+		return cv.forStmt(t)
 	case *ast.RangeStmt:
-		return rangeStmt(t)
+		return cv.rangeStmt(t)
 	}
 	panic("Not Handled: " + reflect.TypeOf(s).String() + " at " + string(s.Pos()))
 }
 
-func declStmt(t *ast.DeclStmt) jen.Code {
-	return genDecl(t.Decl.(*ast.GenDecl))
+func (cv *converter) declStmt(t *ast.DeclStmt) jen.Code {
+	return cv.genDecl(t.Decl.(*ast.GenDecl))
 }
 
 func emptyStmt(t *ast.EmptyStmt) jen.Code {
 	return jen.Id("jen").Dot("Empty").Call()
 }
 
-func exprStmt(t *ast.ExprStmt) jen.Code {
-	return jen.Id("jen").Add(genExpr(t.X))
+func (cv *converter) exprStmt(t *ast.ExprStmt) jen.Code {
+	return jen.Id("jen").Add(cv.genExpr(t.X))
 }
 
-func goStmt(t *ast.GoStmt) jen.Code {
+func (cv *converter) goStmt(t *ast.GoStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Dot("Go").Call().Add(genExpr(t.Call))
+	return ret.Dot("Go").Call().Add(cv.genExpr(t.Call))
 }
 
-func deferStmt(t *ast.DeferStmt) jen.Code {
+func (cv *converter) deferStmt(t *ast.DeferStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Dot("Defer").Call().Add(genExpr(t.Call))
+	return ret.Dot("Defer").Call().Add(cv.genExpr(t.Call))
 }
 
-func labeledStmt(t *ast.LabeledStmt) jen.Code {
+func (cv *converter) labeledStmt(t *ast.LabeledStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Add(ident(t.Label)).Dot("Op").Call(jen.Lit(":")).Dot("Line").Call().Dot("Add").Call(stmt(t.Stmt))
+	return ret.Add(cv.ident(t.Label)).Dot("Op").Call(jen.Lit(":")).Dot("Line").Call().Dot("Add").Call(cv.stmt(t.Stmt))
 }
 
-func sendStmt(t *ast.SendStmt) jen.Code {
+func (cv *converter) sendStmt(t *ast.SendStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Add(genExpr(t.Chan)).Dot("Op").Call(jen.Lit("<-")).Add(genExpr(t.Value))
+	return ret.Add(cv.genExpr(t.Chan)).Dot("Op").Call(jen.Lit("<-")).Add(cv.genExpr(t.Value))
 }
 
-func incDecStmt(t *ast.IncDecStmt) jen.Code {
+func (cv *converter) incDecStmt(t *ast.IncDecStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Add(genExpr(t.X)).Dot("Op").Call(jen.Lit(t.Tok.String()))
+	return ret.Add(cv.genExpr(t.X)).Dot("Op").Call(jen.Lit(t.Tok.String()))
 }
 
-func assignStmt(t *ast.AssignStmt) jen.Code {
+func (cv *converter) assignStmt(t *ast.AssignStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Add(genExprs(t.Lhs)).Dot("Op").Call(jen.Lit(t.Tok.String())).Add(genExprs(t.Rhs))
+	return ret.Add(cv.genExprs(t.Lhs)).Dot("Op").Call(jen.Lit(t.Tok.String())).Add(cv.genExprs(t.Rhs))
 }
 
-func returnStmt(t *ast.ReturnStmt) jen.Code {
+func (cv *converter) returnStmt(t *ast.ReturnStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Dot("Return").Call().Add(genExprs(t.Results))
+	return ret.Dot("Return").Call().Add(cv.genExprs(t.Results))
 }
 
-func caseClause(t *ast.CaseClause) jen.Code {
+func (cv *converter) caseClause(t *ast.CaseClause) jen.Code {
 	ret := jen.Id("jen")
+	body := cv.group(t, "Block", stmtNodes(t.Body), cv.stmtCode)
 	if t.List == nil {
-		return ret.Dot("Default").Call().Dot("Block").Call(stmts(t.Body)...)
+		return ret.Dot("Default").Call().Add(body)
 	}
-	return ret.Dot("Case").Call(genExprsCode(t.List)...).Dot("Block").Call(stmts(t.Body)...)
+	return ret.Dot("Case").Call(cv.genExprsCode(t.List)...).Add(body)
 }
 
-func typeSwitchStmt(t *ast.TypeSwitchStmt) jen.Code {
+func (cv *converter) typeSwitchStmt(t *ast.TypeSwitchStmt) jen.Code {
 	ret := jen.Id("jen")
 	var cond []jen.Code
 	if t.Init != nil {
-		cond = append(cond, stmt(t.Init))
+		cond = append(cond, cv.stmt(t.Init))
 	}
 	if t.Assign != nil {
-		cond = append(cond, stmt(t.Assign))
+		cond = append(cond, cv.stmt(t.Assign))
 	}
-	return ret.Dot("Switch").Call(cond...).Add(blockStmt(t.Body))
+	return ret.Dot("Switch").Call(cond...).Add(cv.blockStmt(t.Body))
 }
 
-func commClause(t *ast.CommClause) jen.Code {
+func (cv *converter) commClause(t *ast.CommClause) jen.Code {
 	ret := jen.Id("jen")
+	body := cv.group(t, "Block", stmtNodes(t.Body), cv.stmtCode)
 	if t.Comm == nil {
-		return ret.Dot("Default").Call().Dot("Block").Call(stmts(t.Body)...)
+		return ret.Dot("Default").Call().Add(body)
 	}
-	return ret.Dot("Case").Call(stmt(t.Comm)).Dot("Block").Call(stmts(t.Body)...)
+	return ret.Dot("Case").Call(cv.stmt(t.Comm)).Add(body)
 }
 
-func selectStmt(t *ast.SelectStmt) jen.Code {
+func (cv *converter) selectStmt(t *ast.SelectStmt) jen.Code {
 	ret := jen.Id("jen")
-	return ret.Dot("Select").Call().Add(blockStmt(t.Body))
+	return ret.Dot("Select").Call().Add(cv.blockStmt(t.Body))
 }
 
-func branchStmt(t *ast.BranchStmt) jen.Code {
+func (cv *converter) branchStmt(t *ast.BranchStmt) jen.Code {
 	ret := jen.Id("jen")
 	switch t.Tok {
 	case token.BREAK:
@@ -143,94 +151,85 @@ func branchStmt(t *ast.BranchStmt) jen.Code {
 	case token.CONTINUE:
 		return ret.Dot("Continue").Call()
 	case token.GOTO:
-		return ret.Dot("Goto").Call().Add(ident(t.Label))
+		return ret.Dot("Goto").Call().Add(cv.ident(t.Label))
 	case token.FALLTHROUGH:
 		return ret.Dot("Fallthrough").Call()
 	}
 	return nil
 }
 
-func ifStmt(t *ast.IfStmt) jen.Code {
+func (cv *converter) ifStmt(t *ast.IfStmt) jen.Code {
 	var cond []jen.Code
 	if t.Init != nil {
-		cond = append(cond, stmt(t.Init))
+		cond = append(cond, cv.stmt(t.Init))
 	}
 	if t.Cond != nil {
-		cond = append(cond, jen.Id("jen").Add(genExpr(t.Cond)))
+		cond = append(cond, jen.Id("jen").Add(cv.genExpr(t.Cond)))
 	}
 	ret := jen.Id("jen").Dot("If").Call(
 		cond...,
-	).Add(blockStmt(t.Body))
+	).Add(cv.blockStmt(t.Body))
 	if t.Else != nil {
-		ret.Dot("Else").Call().Add(stmt(t.Else))
+		ret.Dot("Else").Call().Add(cv.stmt(t.Else))
 	}
 	return ret
 }
 
-func switchStmt(t *ast.SwitchStmt) jen.Code {
+func (cv *converter) switchStmt(t *ast.SwitchStmt) jen.Code {
 	var cond []jen.Code
 	if t.Init != nil {
-		cond = append(cond, stmt(t.Init))
+		cond = append(cond, cv.stmt(t.Init))
 	}
 	if t.Tag != nil {
-		cond = append(cond, jen.Id("jen").Add(genExpr(t.Tag)))
+		cond = append(cond, jen.Id("jen").Add(cv.genExpr(t.Tag)))
 	}
-	return jen.Id("jen").Dot("Switch").Call(cond...).Add(blockStmt(t.Body))
+	return jen.Id("jen").Dot("Switch").Call(cond...).Add(cv.blockStmt(t.Body))
 }
 
-func forStmt(t *ast.ForStmt) jen.Code {
+func (cv *converter) forStmt(t *ast.ForStmt) jen.Code {
 	ret := jen.Id("jen")
 	var code []jen.Code
 	if t.Init != nil {
-		code = append(code, stmt(t.Init))
+		code = append(code, cv.stmt(t.Init))
 	}
 	if t.Init == nil && t.Cond != nil && t.Post != nil {
 		code = append(code, jen.Id("jen").Dot("Empty").Call())
 	}
 	if t.Cond != nil {
-		code = append(code, jen.Id("jen").Add(genExpr(t.Cond)))
+		code = append(code, jen.Id("jen").Add(cv.genExpr(t.Cond)))
 	}
 	if t.Post != nil {
-		code = append(code, stmt(t.Post))
+		code = append(code, cv.stmt(t.Post))
 	}
 	return ret.Dot("For").Call(
 		code...,
-	).Add(blockStmt(t.Body))
+	).Add(cv.blockStmt(t.Body))
 }
 
-func rangeStmt(t *ast.RangeStmt) jen.Code {
+func (cv *converter) rangeStmt(t *ast.RangeStmt) jen.Code {
 	return jen.Id("jen").Dot("For").Call(
 		jen.Id("jen").Add(
-			jen.Dot("List").Call(genExprsCode([]ast.Expr{t.Key, t.Value})...),
+			jen.Dot("List").Call(cv.genExprsCode([]ast.Expr{t.Key, t.Value})...),
 		).Dot("Op").Call(
 			jen.Lit(t.Tok.String()),
-		).Dot("Range").Call().Add(genExpr(t.X)),
-	).Add(blockStmt(t.Body))
+		).Dot("Range").Call().Add(cv.genExpr(t.X)),
+	).Add(cv.blockStmt(t.Body))
 }
 
-func blockStmt(s *ast.BlockStmt) jen.Code {
-	ret := stmts(s.List)
-	return jen.Dot("Block").Call(ret...)
+func (cv *converter) blockStmt(s *ast.BlockStmt) jen.Code {
+	if c, ok := cv.subst(s); ok {
+		return c
+	}
+	return cv.group(s, "Block", stmtNodes(s.List), cv.stmtCode)
 }
 
-func stmts(s []ast.Stmt) []jen.Code {
-	var ret []jen.Code
-	for _, st := range s {
-		ret = append(ret, stmt(st))
-	}
-	return ret
+func (cv *converter) fieldList(fl *ast.FieldList, method string) jen.Code {
+	return cv.group(fl, method, fieldNodes(fl), cv.fieldCode)
 }
 
-func fieldList(fl *ast.FieldList) []jen.Code {
-	var paramsCode []jen.Code
-	if fl == nil {
-		return paramsCode
-	}
-	for _, p := range fl.List {
-		code := jen.Id("jen")
-		code.Add(identsList(p.Names))
-		code.Add(genExpr(p.Type))
-		paramsCode = append(paramsCode, code)
-	}
-	return paramsCode
+func (cv *converter) field(p *ast.Field) jen.Code {
+	code := jen.Id("jen")
+	code.Add(cv.identsList(p.Names))
+	code.Add(cv.genExpr(p.Type))
+	return code
 }
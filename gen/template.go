@@ -0,0 +1,177 @@
+package gen
+
+import (
+	"go/ast"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// template describes where the generated code deviates from the source.
+type template struct {
+	// params are taken by every generated function and args are passed on
+	// to them by genFile
+	params []jen.Code
+	args   []jen.Code
+	// decls are added to the output after genFile
+	decls []jen.Code
+	// main is the body of the generated main function
+	main []jen.Code
+	// substs replaces the code generated for a node
+	substs map[ast.Node]jen.Code
+	// names replaces the string of an identifier
+	names map[*ast.Ident]jen.Code
+	// plans replaces the elements of a list container such as a block
+	plans map[ast.Node][]entry
+	// calls replaces the arguments of a call
+	calls map[*ast.CallExpr]jen.Code
+	// prefix is put before the names of the variables holding data
+	prefix string
+	// comments are the comments of the source that are generated
+	comments map[*ast.CommentGroup]bool
+}
+
+func newTemplate() *template {
+	return &template{
+		substs:   map[ast.Node]jen.Code{},
+		names:    map[*ast.Ident]jen.Code{},
+		plans:    map[ast.Node][]entry{},
+		calls:    map[*ast.CallExpr]jen.Code{},
+		comments: map[*ast.CommentGroup]bool{},
+	}
+}
+
+// entry is an element of a planned list. It is generated once, only when
+// cond holds or, when rng is set, once for every element of rng with the
+// element bound to v.
+type entry struct {
+	node ast.Node
+	cond jen.Code
+	rng  jen.Code
+	v    string
+	// typ is the type of v, needed when node is a declaration that gets its
+	// own generator function
+	typ jen.Code
+	// name, params and args replace the name of the generator function of a
+	// declaration and the parameters it takes from genFile
+	name   string
+	params []jen.Code
+	args   []jen.Code
+}
+
+// wrap puts code inside the condition or loop of the entry
+func (e entry) wrap(code jen.Code) jen.Code {
+	switch {
+	case e.rng != nil:
+		return jen.For(
+			jen.List(jen.Id("_"), jen.Id(e.v)).Op(":=").Range().Add(e.rng),
+		).Block(code)
+	case e.cond != nil:
+		return jen.If(e.cond).Block(code)
+	}
+	return code
+}
+
+func (cv *converter) subst(n ast.Node) (jen.Code, bool) {
+	if cv.tmpl == nil {
+		return nil, false
+	}
+	c, ok := cv.tmpl.substs[n]
+	return c, ok
+}
+
+// name returns the code for the string of an identifier
+func (cv *converter) name(s *ast.Ident) jen.Code {
+	if cv.tmpl != nil {
+		if c, ok := cv.tmpl.names[s]; ok {
+			return c
+		}
+	}
+	return jen.Lit(s.String())
+}
+
+func (cv *converter) callArgs(c *ast.CallExpr) (jen.Code, bool) {
+	if cv.tmpl == nil {
+		return nil, false
+	}
+	args, ok := cv.tmpl.calls[c]
+	return args, ok
+}
+
+func (cv *converter) plan(parent ast.Node) []entry {
+	if cv.tmpl == nil {
+		return nil
+	}
+	return cv.tmpl.plans[parent]
+}
+
+// group generates a call to a jennifer group method such as Block with the
+// converted nodes as arguments. When the list is planned the Func variant of
+// the method is used so that the generator can skip or repeat elements.
+func (cv *converter) group(parent ast.Node, method string, nodes []ast.Node, conv func(ast.Node) jen.Code) jen.Code {
+	p := cv.plan(parent)
+	if p == nil {
+		var code []jen.Code
+		for _, n := range nodes {
+			code = append(code, conv(n))
+		}
+		return jen.Dot(method).Call(code...)
+	}
+	var body []jen.Code
+	for _, e := range p {
+		body = append(body, e.wrap(jen.Id("g").Dot("Add").Call(conv(e.node))))
+	}
+	return jen.Dot(method + "Func").Call(
+		jen.Func().Params(jen.Id("g").Op("*").Qual(jenImp, "Group")).Block(body...),
+	)
+}
+
+func stmtNodes(s []ast.Stmt) []ast.Node {
+	var ret []ast.Node
+	for _, n := range s {
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+func exprNodes(s []ast.Expr) []ast.Node {
+	var ret []ast.Node
+	for _, n := range s {
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+func fieldNodes(fl *ast.FieldList) []ast.Node {
+	var ret []ast.Node
+	if fl == nil {
+		return ret
+	}
+	for _, n := range fl.List {
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+func specNodes(s []ast.Spec) []ast.Node {
+	var ret []ast.Node
+	for _, n := range s {
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+func (cv *converter) stmtCode(n ast.Node) jen.Code {
+	return cv.stmt(n.(ast.Stmt))
+}
+
+func (cv *converter) exprCode(n ast.Node) jen.Code {
+	return jen.Id("jen").Add(cv.genExpr(n.(ast.Expr)))
+}
+
+func (cv *converter) fieldCode(n ast.Node) jen.Code {
+	return cv.field(n.(*ast.Field))
+}
+
+func (cv *converter) specCode(n ast.Node) jen.Code {
+	return jen.Id("jen").Add(cv.genSpec(n.(ast.Spec)))
+}
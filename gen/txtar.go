@@ -0,0 +1,101 @@
+package gen
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Txtar is a txtar archive, a comment followed by named files each starting
+// with a "-- name --" line
+type Txtar struct {
+	Comment []byte
+	Files   []TxtarFile
+}
+
+// TxtarFile is a file of a txtar archive
+type TxtarFile struct {
+	Name string
+	Data []byte
+}
+
+// ParseTxtar parses a txtar archive
+func ParseTxtar(data []byte) *Txtar {
+	a := &Txtar{}
+	var name string
+	var cur *bytes.Buffer
+	comment := &bytes.Buffer{}
+	cur = comment
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line, data = data[:i+1], data[i+1:]
+		} else {
+			data = nil
+		}
+		if n, ok := txtarMarker(line); ok {
+			if cur != comment {
+				a.Files = append(a.Files, TxtarFile{Name: name, Data: cur.Bytes()})
+			}
+			name, cur = n, &bytes.Buffer{}
+			continue
+		}
+		cur.Write(line)
+	}
+	if cur != comment {
+		a.Files = append(a.Files, TxtarFile{Name: name, Data: cur.Bytes()})
+	}
+	a.Comment = comment.Bytes()
+	return a
+}
+
+func txtarMarker(line []byte) (string, bool) {
+	s := strings.TrimRight(string(line), "\r\n")
+	if !strings.HasPrefix(s, "-- ") || !strings.HasSuffix(s, " --") || len(s) < 6 {
+		return "", false
+	}
+	return strings.TrimSpace(s[3 : len(s)-3]), true
+}
+
+// Format returns the archive in txtar format
+func (a *Txtar) Format() []byte {
+	b := &bytes.Buffer{}
+	b.Write(withNewline(a.Comment))
+	for _, f := range a.Files {
+		b.WriteString("-- " + f.Name + " --\n")
+		b.Write(withNewline(f.Data))
+	}
+	return b.Bytes()
+}
+
+func withNewline(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		return append(b[:len(b):len(b)], '\n')
+	}
+	return b
+}
+
+// GoFiles returns the Go files of the archive by name
+func (a *Txtar) GoFiles() map[string][]byte {
+	ret := map[string][]byte{}
+	for _, f := range a.Files {
+		if strings.HasSuffix(f.Name, ".go") {
+			ret[f.Name] = f.Data
+		}
+	}
+	return ret
+}
+
+// TxtarOf returns an archive of the files in the order of their names
+func TxtarOf(files map[string][]byte) *Txtar {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	a := &Txtar{}
+	for _, name := range names {
+		a.Files = append(a.Files, TxtarFile{Name: name, Data: files[name]})
+	}
+	return a
+}
@@ -6,23 +6,42 @@ import (
 	"github.com/dave/jennifer/jen"
 )
 
-func funcType(s *ast.FuncType) jen.Code {
+func (cv *converter) funcType(s *ast.FuncType) jen.Code {
+	if c, ok := cv.subst(s); ok {
+		return c
+	}
+	if s.TypeParams != nil {
+		cv.warn(s.TypeParams, "generics", "type parameters are dropped")
+	}
 	var ret jen.Statement
-	params := fieldList(s.Params)
-	ret.Dot("Params").Call(params...)
-	results := fieldList(s.Results)
-	if len(results) > 0 {
-		ret.Dot("Params").Call(results...)
+	ret.Add(cv.fieldList(s.Params, "Params"))
+	if s.Results != nil && (len(s.Results.List) > 0 || cv.plan(s.Results) != nil) {
+		ret.Add(cv.fieldList(s.Results, "Params"))
 	}
 	return &ret
 }
-func arrayType(s *ast.ArrayType) jen.Code {
-	return jen.Dot("Index").Call().Add(genExpr(s.Elt))
+func (cv *converter) arrayType(s *ast.ArrayType) jen.Code {
+	return jen.Dot("Index").Call().Add(cv.genExpr(s.Elt))
+}
+func (cv *converter) structType(s *ast.StructType) jen.Code {
+	for _, f := range s.Fields.List {
+		if f.Tag != nil {
+			cv.warn(f.Tag, "tag", "struct tag %s is dropped", f.Tag.Value)
+		}
+	}
+	return cv.fieldList(s.Fields, "Struct")
 }
-func structType(s *ast.StructType) jen.Code {
-	return jen.Dot("Struct").Call(fieldList(s.Fields)...)
+
+func (cv *converter) interfaceType(s *ast.InterfaceType) jen.Code {
+	return cv.group(s.Methods, "Interface", fieldNodes(s.Methods), cv.methodCode)
 }
 
-func interfaceType(s *ast.InterfaceType) jen.Code {
-	return jen.Dot("Interface").Call(fieldList(s.Methods)...)
+// methodCode converts a method of an interface, its type has no func keyword
+func (cv *converter) methodCode(n ast.Node) jen.Code {
+	p := n.(*ast.Field)
+	ft, ok := p.Type.(*ast.FuncType)
+	if !ok {
+		return cv.field(p)
+	}
+	return jen.Id("jen").Add(cv.identsList(p.Names)).Add(cv.funcType(ft))
 }
@@ -0,0 +1,362 @@
+package gen
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// Merge is the result of updating an edited generator
+type Merge struct {
+	Code []byte
+	// Updated are the declarations taken from the new generator
+	Updated []string
+	// Kept are the edited declarations whose source did not change
+	Kept []string
+	// Merged are the edited declarations merged with their new version
+	Merged []string
+	// Conflicts are the declarations with edits conflicting with the new
+	// generator, marked in Code like git does
+	Conflicts []string
+}
+
+// genDeclAt matches the names of generators of declarations, which change
+// with the position of the declaration in the source
+var genDeclAt = regexp.MustCompile(`^genDeclAt[0-9]+$`)
+
+// genDecl is a top level declaration of a generator
+type genDecl struct {
+	key  string
+	name *ast.Ident
+	// code is the code of the declaration with its doc comment and nameOff
+	// the offset of its name in the code
+	code    string
+	nameOff int
+}
+
+// renamed returns the code of d with its name replaced
+func (d *genDecl) renamed(name string) string {
+	if d.name == nil {
+		return d.code
+	}
+	return d.code[:d.nameOff] + name + d.code[d.nameOff+len(d.name.Name):]
+}
+
+// body is the code of d without its name, the same for generators of the
+// same declaration at different positions
+func (d *genDecl) body() string {
+	return d.renamed("")
+}
+
+// Update merges the changes from base, the generator tojen generated last,
+// to theirs, the generator of the current source, into ours, base with hand
+// edits. Every top level declaration is merged on its own. Declarations
+// without edits are replaced with their new version and edited ones are kept
+// when their new version is the same as in base. Otherwise the lines of the
+// edits are merged.
+func Update(base, ours, theirs []byte) (*Merge, error) {
+	bf, err := genDecls(base)
+	if err != nil {
+		return nil, err
+	}
+	of, err := genDecls(ours)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := genDecls(theirs)
+	if err != nil {
+		return nil, err
+	}
+	pairMoved(bf, tf)
+
+	m := &Merge{}
+	var decls []string
+	for _, key := range tf.keys {
+		t := tf.decls[key]
+		b, o := bf.decls[key], of.decls[key]
+		name := key
+		if t.name != nil {
+			name = t.name.Name
+		}
+		switch {
+		case o == nil && b != nil:
+			// deleted by hand
+			continue
+		case o == nil, b == nil && o.body() == t.body():
+			m.Updated = append(m.Updated, name)
+			decls = append(decls, t.code)
+		case b == nil:
+			// added by hand and by the new generator
+			code, ok := merge3("", o.renamed(nameOf(t)), t.code)
+			decls = append(decls, code)
+			m.add(name, ok)
+		case o.body() == b.body():
+			m.Updated = append(m.Updated, name)
+			decls = append(decls, t.code)
+		case t.body() == b.body():
+			m.Kept = append(m.Kept, name)
+			decls = append(decls, o.renamed(nameOf(t)))
+		default:
+			code, ok := merge3(b.renamed(nameOf(t)), o.renamed(nameOf(t)), t.code)
+			decls = append(decls, code)
+			m.add(name, ok)
+		}
+	}
+	for _, key := range of.keys {
+		if tf.decls[key] != nil {
+			continue
+		}
+		o, b := of.decls[key], bf.decls[key]
+		switch {
+		case b == nil:
+			// added by hand
+			decls = append(decls, o.code)
+		case o.body() != b.body():
+			// edited by hand and removed from the source
+			code, _ := merge3(b.code, o.code, "")
+			decls = append(decls, code)
+			m.Conflicts = append(m.Conflicts, key)
+		}
+	}
+
+	code := "package " + tf.pkg + "\n\n" + imports3(bf.imports, of.imports, tf.imports) + strings.Join(decls, "\n\n") + "\n"
+	m.Code = []byte(code)
+	if b, err := format.Source(m.Code); err == nil {
+		m.Code = b
+	}
+	return m, nil
+}
+
+func (m *Merge) add(name string, ok bool) {
+	if ok {
+		m.Merged = append(m.Merged, name)
+	} else {
+		m.Conflicts = append(m.Conflicts, name)
+	}
+}
+
+func nameOf(d *genDecl) string {
+	if d.name == nil {
+		return ""
+	}
+	return d.name.Name
+}
+
+// genFile are the declarations of a generator by key in their order
+type genFile struct {
+	pkg     string
+	imports []string
+	keys    []string
+	decls   map[string]*genDecl
+}
+
+func genDecls(code []byte) (*genFile, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	ret := &genFile{pkg: f.Name.Name, decls: map[string]*genDecl{}}
+	for _, imp := range f.Imports {
+		start, end := fset.Position(imp.Pos()).Offset, fset.Position(imp.End()).Offset
+		ret.imports = append(ret.imports, string(code[start:end]))
+	}
+	for _, decl := range f.Decls {
+		d := &genDecl{}
+		start := decl.Pos()
+		switch t := decl.(type) {
+		case *ast.FuncDecl:
+			d.name = t.Name
+			d.key = t.Name.Name
+			if t.Recv != nil && len(t.Recv.List) > 0 {
+				d.key = recvName(t.Recv.List[0].Type) + "." + d.key
+			}
+			if t.Doc != nil {
+				start = t.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if t.Tok == token.IMPORT {
+				continue
+			}
+			if t.Doc != nil {
+				start = t.Doc.Pos()
+			}
+			d.key = t.Tok.String()
+			for _, spec := range t.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					d.key += " " + s.Name.Name
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						d.key += " " + n.Name
+					}
+				}
+			}
+		}
+		off := fset.Position(start).Offset
+		d.code = string(code[off:fset.Position(decl.End()).Offset])
+		if d.name != nil {
+			d.nameOff = fset.Position(d.name.Pos()).Offset - off
+		}
+		if ret.decls[d.key] == nil {
+			ret.keys = append(ret.keys, d.key)
+		}
+		ret.decls[d.key] = d
+	}
+	return ret, nil
+}
+
+// recvName returns the name of the type of a receiver
+func recvName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return recvName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// pairMoved keys the generators of declarations in f, whose names change
+// with their position in the source, like the generators of the same code in
+// base. The remaining ones keep their keys when base has no other generator
+// with the name.
+func pairMoved(base, f *genFile) {
+	byBody := map[string]string{}
+	for _, key := range base.keys {
+		if genDeclAt.MatchString(key) {
+			byBody[base.decls[key].body()] = key
+		}
+	}
+	paired := map[string]bool{}
+	rename := map[string]string{}
+	for _, key := range f.keys {
+		if bk, ok := byBody[f.decls[key].body()]; ok && genDeclAt.MatchString(key) && !paired[bk] {
+			rename[key] = bk
+			paired[bk] = true
+		}
+	}
+	for _, key := range f.keys {
+		if _, ok := rename[key]; ok || !genDeclAt.MatchString(key) {
+			continue
+		}
+		if base.decls[key] != nil && !paired[key] {
+			paired[key] = true
+			rename[key] = key
+		} else {
+			rename[key] = "new " + key
+		}
+	}
+	decls := map[string]*genDecl{}
+	for i, key := range f.keys {
+		if k, ok := rename[key]; ok {
+			f.keys[i] = k
+		}
+		decls[f.keys[i]] = f.decls[key]
+	}
+	f.decls = decls
+}
+
+// imports3 merges the imports and returns the import declaration
+func imports3(base, ours, theirs []string) string {
+	seen := map[string]bool{}
+	var specs []string
+	for _, imp := range theirs {
+		seen[imp] = true
+		specs = append(specs, imp)
+	}
+	removed := map[string]bool{}
+	for _, imp := range base {
+		removed[imp] = true
+	}
+	for _, imp := range ours {
+		if !seen[imp] && !removed[imp] {
+			seen[imp] = true
+			specs = append(specs, imp)
+		}
+	}
+	if len(specs) == 0 {
+		return ""
+	}
+	return "import (\n\t" + strings.Join(specs, "\n\t") + "\n)\n\n"
+}
+
+// merge3 merges the changes from base to ours and from base to theirs line by
+// line. Lines both change differently are marked as a conflict and ok is
+// false.
+func merge3(base, ours, theirs string) (string, bool) {
+	b, o, t := splitLines([]byte(base)), splitLines([]byte(ours)), splitLines([]byte(theirs))
+	mo, mt := matches(b, o), matches(b, t)
+	var ret []string
+	ok := true
+	i, j, k := 0, 0, 0
+	for {
+		// find the next line of base kept by both
+		s := i
+		for s < len(b) && (mo[s] < 0 || mt[s] < 0) {
+			s++
+		}
+		oEnd, tEnd := len(o), len(t)
+		if s < len(b) {
+			oEnd, tEnd = mo[s], mt[s]
+		}
+		bc, oc, tc := b[i:s], o[j:oEnd], t[k:tEnd]
+		switch {
+		case equalLines(oc, bc):
+			ret = append(ret, tc...)
+		case equalLines(tc, bc), equalLines(oc, tc):
+			ret = append(ret, oc...)
+		default:
+			ok = false
+			ret = append(ret, "<<<<<<< ours")
+			ret = append(ret, oc...)
+			ret = append(ret, "||||||| base")
+			ret = append(ret, bc...)
+			ret = append(ret, "=======")
+			ret = append(ret, tc...)
+			ret = append(ret, ">>>>>>> new")
+		}
+		if s == len(b) {
+			break
+		}
+		ret = append(ret, b[s])
+		i, j, k = s+1, oEnd+1, tEnd+1
+	}
+	return strings.Join(ret, "\n"), ok
+}
+
+// matches returns the index in b of every line of a, or -1 for lines not in
+// their longest common subsequence
+func matches(a, b []string) []int {
+	ret := make([]int, len(a))
+	i, j := 0, 0
+	for _, op := range diffLines(a, b) {
+		switch op.kind {
+		case ' ':
+			ret[i] = j
+			i++
+			j++
+		case '-':
+			ret[i] = -1
+			i++
+		case '+':
+			j++
+		}
+	}
+	return ret
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const updateSource = `package main
+
+import "fmt"
+
+type User struct {
+	Name string
+}
+
+func main() {
+	fmt.Println("hi")
+}
+`
+
+func TestUpdate(t *testing.T) {
+	base, err := GenerateFileBytes([]byte(updateSource), "main", false, false)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	// the struct and the file are edited by hand
+	ours := strings.Replace(string(base), `jen.Id("Name").Id("string")`, `jen.Id("Name").Id("string"), jen.Id("Age").Int()`, 1)
+	ours = strings.Replace(ours, "\tret := jen.NewFile(\"main\")\n", "\tret := jen.NewFile(\"main\")\n\tret.HeaderComment(\"edited\")\n", 1)
+
+	// a declaration moves the others and main changes
+	src := strings.Replace(updateSource, "type User", "var x = 1\n\ntype User", 1)
+	src = strings.Replace(src, `"hi"`, `"hello"`, 1)
+	theirs, err := GenerateFileBytes([]byte(src), "main", false, false)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+
+	m, err := Update(base, []byte(ours), theirs)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	code := string(m.Code)
+	assert.Equal(t, []string{"genDeclAt40"}, m.Kept, code)
+	assert.Equal(t, []string{"genFile"}, m.Merged, code)
+	assert.Empty(t, m.Conflicts, code)
+	assert.Contains(t, code, "func genDeclAt40() jen.Code {\n\treturn jen.Null().Type().Id(\"User\").Struct(jen.Id(\"Name\").Id(\"string\"), jen.Id(\"Age\").Int())")
+	assert.Contains(t, code, `jen.Lit("hello")`)
+	assert.Contains(t, code, "\tret.HeaderComment(\"edited\")\n")
+	assert.Contains(t, code, "\tret.Add(genDeclAt40())\n")
+	assert.Contains(t, code, "func genDeclAt29() jen.Code {\n\treturn jen.Null().Var()")
+
+	// main is edited by hand and in the source
+	ours = strings.Replace(string(base), `jen.Lit("hi")`, `jen.Lit("hey")`, 1)
+	m, err = Update(base, []byte(ours), theirs)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	assert.Equal(t, []string{"genFuncmain"}, m.Conflicts)
+	assert.Contains(t, string(m.Code), "<<<<<<< ours\n")
+}
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne"
+	merged, ok := merge3(base, "a\nB\nc\nd\ne", "a\nb\nc\nD\ne")
+	assert.True(t, ok)
+	assert.Equal(t, "a\nB\nc\nD\ne", merged)
+
+	merged, ok = merge3(base, "a\nB\nc\nd\ne", "a\nX\nc\nd\ne")
+	assert.False(t, ok)
+	assert.Equal(t, "a\n<<<<<<< ours\nB\n||||||| base\nb\n=======\nX\n>>>>>>> new\nc\nd\ne", merged)
+
+	merged, ok = merge3(base, "a\nb\nc\nd\ne", "a\nc\nd\ne\nf")
+	assert.True(t, ok)
+	assert.Equal(t, "a\nc\nd\ne\nf", merged)
+}
@@ -0,0 +1,223 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aloder/tojen/run"
+)
+
+// Divergence is the first node where the code rendered by a generator
+// differs from its source
+type Divergence struct {
+	// Want is the position of the node in the source
+	Want token.Position
+	// Got is the position of the node in the rendered code
+	Got token.Position
+	// WantNode and GotNode are the nodes printed on one line
+	WantNode, GotNode string
+	// Reason tells how the nodes differ
+	Reason string
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("%s: %s\n\tsource:   %s\n\t%s: rendered: %s", d.Want, d.Reason, d.WantNode, d.Got, d.GotNode)
+}
+
+// Verify converts the source into a generator, runs the generator and
+// compares the code it renders to the source. Comments, formatting and the
+// spelling of literals are ignored. A *Divergence is returned when the code
+// differs.
+func Verify(name string, src []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", name, r)
+		}
+	}()
+	b := &bytes.Buffer{}
+	if err := GenerateFile(src, "main", true).Render(b); err != nil {
+		return err
+	}
+	out, err := run.Exec(b.String())
+	if err != nil {
+		return fmt.Errorf("running the generator of %s: %v", name, err)
+	}
+	// the generator prints the panic of jennifer when the code it renders
+	// does not format
+	if m := renderPanic.FindStringSubmatch(*out); m != nil {
+		return fmt.Errorf("rendering the generator of %s: %s", name, m[1])
+	}
+	return Compare(name, src, "rendered.go", []byte(*out))
+}
+
+var renderPanic = regexp.MustCompile(`(?s)^%!v\(PANIC=GoString method: (.*)\)$`)
+
+// Compare parses want and got and returns the first node where they differ
+func Compare(wantName string, want []byte, gotName string, got []byte) error {
+	fset := token.NewFileSet()
+	w, err := parser.ParseFile(fset, wantName, want, 0)
+	if err != nil {
+		return err
+	}
+	g, err := parser.ParseFile(fset, gotName, got, 0)
+	if err != nil {
+		return err
+	}
+	c := &comparer{fset: fset, src: map[*token.File][]byte{fset.File(w.Pos()): want, fset.File(g.Pos()): got}}
+	c.value(reflect.ValueOf(sortImports(w)), reflect.ValueOf(sortImports(g)), w, g)
+	if c.diff != nil {
+		return c.diff
+	}
+	return nil
+}
+
+// sortImports sorts the imports of the declarations of f by path, since the
+// order of imports does not matter and jennifer renders them sorted
+func sortImports(f *ast.File) *ast.File {
+	for _, d := range f.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+			sort.SliceStable(g.Specs, func(i, j int) bool {
+				return g.Specs[i].(*ast.ImportSpec).Path.Value < g.Specs[j].(*ast.ImportSpec).Path.Value
+			})
+		}
+	}
+	return f
+}
+
+type comparer struct {
+	fset *token.FileSet
+	src  map[*token.File][]byte
+	diff *Divergence
+}
+
+// value compares a and b, the innermost nodes holding them being wn and gn.
+// It returns false at the first difference.
+func (c *comparer) value(a, b reflect.Value, wn, gn ast.Node) bool {
+	// the nodes in interfaces and slices are where a difference is found
+	if n := heldNode(a); n != nil {
+		wn = n
+	}
+	if n := heldNode(b); n != nil {
+		gn = n
+	}
+	if a.Type() != b.Type() {
+		return c.fail(wn, gn, fmt.Sprintf("%s instead of %s", typeName(b), typeName(a)))
+	}
+	if l, ok := wn.(*ast.BasicLit); ok && a.Kind() == reflect.Ptr && !a.IsNil() && !b.IsNil() {
+		return c.basicLit(l, gn.(*ast.BasicLit))
+	}
+	switch a.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return true
+		case a.IsNil():
+			return c.fail(wn, gn, "unexpected "+typeName(b))
+		case b.IsNil():
+			return c.fail(wn, gn, "missing "+typeName(a))
+		}
+		return c.value(a.Elem(), b.Elem(), wn, gn)
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			f := a.Type().Field(i)
+			switch f.Type {
+			case posType, commentType, commentsType, objectType, scopeType, importsType:
+				continue
+			}
+			if f.Name == "Unresolved" {
+				continue
+			}
+			if !c.value(a.Field(i), b.Field(i), wn, gn) {
+				return false
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			if !c.value(a.Index(i), b.Index(i), wn, gn) {
+				return false
+			}
+		}
+		if a.Len() != b.Len() {
+			return c.fail(wn, gn, fmt.Sprintf("%d elements instead of %d", b.Len(), a.Len()))
+		}
+	default:
+		if a.Interface() != b.Interface() {
+			return c.fail(wn, gn, fmt.Sprintf("%v instead of %v", b.Interface(), a.Interface()))
+		}
+	}
+	return true
+}
+
+// heldNode returns the node v holds or nil
+func heldNode(v reflect.Value) ast.Node {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() || !v.Type().Implements(nodeType) {
+		return nil
+	}
+	return v.Interface().(ast.Node)
+}
+
+// basicLit compares the values of literals
+func (c *comparer) basicLit(a, b *ast.BasicLit) bool {
+	if a.Kind == b.Kind && litValue(a) == litValue(b) {
+		return true
+	}
+	return c.fail(a, b, fmt.Sprintf("%s instead of %s", b.Value, a.Value))
+}
+
+func litValue(l *ast.BasicLit) string {
+	switch l.Kind {
+	case token.STRING, token.CHAR:
+		if s, err := strconv.Unquote(l.Value); err == nil {
+			return s
+		}
+	default:
+		if v := constant.MakeFromLiteral(l.Value, l.Kind, 0); v.Kind() != constant.Unknown {
+			return v.ExactString()
+		}
+	}
+	return l.Value
+}
+
+func (c *comparer) fail(wn, gn ast.Node, reason string) bool {
+	c.diff = &Divergence{
+		Want:     c.fset.Position(wn.Pos()),
+		Got:      c.fset.Position(gn.Pos()),
+		WantNode: c.line(wn),
+		GotNode:  c.line(gn),
+		Reason:   reason,
+	}
+	return false
+}
+
+// line returns the code of a node on one line, cut to a readable length
+func (c *comparer) line(n ast.Node) string {
+	if _, ok := n.(*ast.File); ok {
+		return "the file"
+	}
+	f := c.fset.File(n.Pos())
+	code := c.src[f][f.Offset(n.Pos()):f.Offset(n.End())]
+	s := strings.Join(strings.Fields(string(code)), " ")
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
+
+func typeName(v reflect.Value) string {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	return strings.TrimPrefix(v.Type().String(), "*ast.")
+}
@@ -0,0 +1,90 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var compareTests = []struct {
+	Name string
+	Want string
+	Got  string
+	// Diff is the reason and the line and column in both files of the first
+	// difference, empty when the code is the same
+	Diff string
+}{
+	{
+		Name: "formatting",
+		Want: "package main\n\n// main does nothing\nfunc main() {\n\tx := 0x10 // x\n\t_ = `a`\n}\n",
+		Got:  "package main\nfunc main() { x := 16; _ = \"a\" }\n",
+	},
+	{
+		Name: "import order",
+		Want: "package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+		Got:  "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+	},
+	{
+		Name: "ident",
+		Want: "package main\n\nfunc main() {\n\tprintln(a)\n}\n",
+		Got:  "package main\n\nfunc main() {\n\n\tprintln(b)\n}\n",
+		Diff: "b instead of a 4:10 5:10",
+	},
+	{
+		Name: "literal",
+		Want: "package main\n\nvar x = 1.5\n",
+		Got:  "package main\n\nvar x = 2.5\n",
+		Diff: "2.5 instead of 1.5 3:9 3:9",
+	},
+	{
+		Name: "node type",
+		Want: "package main\n\nvar x = y\n",
+		Got:  "package main\n\nvar x = f()\n",
+		Diff: "CallExpr instead of Ident 3:9 3:9",
+	},
+	{
+		Name: "missing",
+		Want: "package main\n\nfunc main() {\n\ta()\n\tb()\n}\n",
+		Got:  "package main\n\nfunc main() {\n\ta()\n}\n",
+		Diff: "1 elements instead of 2 3:13 3:13",
+	},
+	{
+		Name: "declaration",
+		Want: "package main\n\nimport \"fmt\"\n\nfunc a() {}\n",
+		Got:  "package main\n\nimport \"fmt\"\n\nvar a int\n",
+		Diff: "GenDecl instead of FuncDecl 5:1 5:1",
+	},
+}
+
+func TestCompare(t *testing.T) {
+	for _, tc := range compareTests {
+		test := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			err := Compare("want.go", []byte(test.Want), "got.go", []byte(test.Got))
+			if test.Diff == "" {
+				assert.Nil(t, err)
+				return
+			}
+			d, ok := err.(*Divergence)
+			if !ok {
+				assert.Fail(t, "no divergence", "%v", err)
+				return
+			}
+			got := d.Reason + " " + d.Want.String()[len("want.go:"):] + " " + d.Got.String()[len("got.go:"):]
+			assert.Equal(t, test.Diff, got, d.Error())
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(`hello`, 1.50)\n}\n"
+	assert.Nil(t, Verify("hello.go", []byte(src)))
+	assert.NotNil(t, Verify("bad.go", []byte("package main\n\nfunc {")))
+
+	// the generator renders a receive-only channel that does not parse
+	err := Verify("chan.go", []byte("package main\n\nfunc main() {\n\tvar c <-chan int\n\t_ = c\n}\n"))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "rendering the generator of chan.go: ")
+		assert.NotContains(t, err.Error(), "rendered.go")
+	}
+}
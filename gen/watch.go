@@ -0,0 +1,48 @@
+package gen
+
+import (
+	"bytes"
+	"time"
+)
+
+// Watch converts the entry and then polls its input every interval,
+// converting it again whenever the Go files change, until stop is closed.
+// report is called after every conversion with its error, so that a broken
+// template does not end the watch.
+func Watch(e BatchEntry, interval time.Duration, stop <-chan struct{}, report func(error)) {
+	var last map[string][]byte
+	var readErr string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		files, err := e.Read()
+		if err != nil {
+			// the input may be missing while it is saved, report it once
+			if err.Error() != readErr {
+				readErr = err.Error()
+				report(err)
+			}
+			last = nil
+		} else if !sameFiles(files, last) {
+			last, readErr = files, ""
+			report(e.Convert(files))
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sameFiles(a, b map[string][]byte) bool {
+	if a == nil || b == nil || len(a) != len(b) {
+		return false
+	}
+	for name, data := range a {
+		if other, ok := b[name]; !ok || !bytes.Equal(data, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package gen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "a.go")
+	out := filepath.Join(dir, "gen", "a.go")
+	err = ioutil.WriteFile(src, []byte("package main\n\nvar A = 1\n"), 0644)
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+
+	reports := make(chan error)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		Watch(BatchEntry{Input: src, Output: out}, 10*time.Millisecond, stop, func(err error) {
+			reports <- err
+		})
+		close(done)
+	}()
+	next := func() error {
+		select {
+		case err := <-reports:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no conversion")
+			return nil
+		}
+	}
+
+	assert.Nil(t, next())
+	b, _ := ioutil.ReadFile(out)
+	assert.Contains(t, string(b), `Id("A")`)
+
+	assert.Nil(t, ioutil.WriteFile(src, []byte("package main\n\nfunc {\n"), 0644))
+	assert.NotNil(t, next())
+
+	assert.Nil(t, ioutil.WriteFile(src, []byte("package main\n\nvar B = 2\n"), 0644))
+	assert.Nil(t, next())
+	b, _ = ioutil.ReadFile(out)
+	assert.Contains(t, string(b), `Id("B")`)
+
+	close(stop)
+	select {
+	case <-reports:
+		t.Error("converted without a change")
+	case <-done:
+	}
+}
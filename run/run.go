@@ -1,37 +1,153 @@
 package run
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
 )
 
-// Exec executes a golang string
+const jenPath = "github.com/dave/jennifer"
+
+// Exec executes a golang string. The code runs in a module of its own in a
+// temporary directory, so that it builds the same wherever it is called
+// from. The module requires the jennifer tojen is built with, or the latest
+// one if that is unknown.
 func Exec(code string) (*string, error) {
+	return ExecFiles(map[string]string{"main.go": code})
+}
+
+// ExecFiles is Exec for a main package of several files, given by name, run
+// with the arguments
+func ExecFiles(files map[string]string, args ...string) (*string, error) {
 	dir, err := ioutil.TempDir("", "goexec")
 	if err != nil {
 		return nil, err
 	}
-	path := filepath.Join(dir, "main.go")
-	file, err := os.Create(path)
-	if err != nil {
-		return nil, err
+	defer os.RemoveAll(dir)
+	for name, code := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(code), 0644)
+		if err != nil {
+			return nil, err
+		}
 	}
-	_, err = file.WriteString(code)
-	if err != nil {
-		return nil, err
-	}
-	err = file.Close()
+	err = Module(dir, "goexec")
 	if err != nil {
 		return nil, err
 	}
 
-	cmd := exec.Command("go", "run", path)
+	cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+	cmd.Dir = dir
 	bout, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%v: %s", err, bout)
 	}
 	str := string(bout)
 	return &str, nil
 }
+
+// Module writes the go.mod of a module requiring the jennifer tojen is built
+// with to dir, and the go.sum of the packages the Go files in dir import
+func Module(dir, module string) error {
+	err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod(module)), 0644)
+	if err != nil {
+		return err
+	}
+	// the sums of jennifer in the module tojen is run in save a trip to the
+	// checksum database, tidy adds the others
+	if sum := jenSums(moduleRoot()); sum != "" {
+		err = ioutil.WriteFile(filepath.Join(dir, "go.sum"), []byte(sum), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = dir
+	if bout, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, bout)
+	}
+	return nil
+}
+
+// goMod returns the go.mod of a module
+func goMod(module string) string {
+	mod := "module " + module + "\n"
+	if v := goVersion(runtime.Version()); v != "" {
+		mod += "\ngo " + v + "\n"
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return mod
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != jenPath {
+			continue
+		}
+		mod += "\nrequire " + jenPath + " " + dep.Version + "\n"
+		if r := dep.Replace; r != nil {
+			path := r.Path
+			if strings.HasPrefix(path, ".") {
+				path = filepath.Join(moduleRoot(), path)
+			}
+			mod += "\nreplace " + jenPath + " => " + strings.TrimSpace(path+" "+r.Version) + "\n"
+		}
+	}
+	return mod
+}
+
+// goVersion returns the language version of a go release like go1.22.5, or
+// an empty string for a development build
+func goVersion(release string) string {
+	parts := strings.Split(strings.TrimPrefix(release, "go"), ".")
+	if len(parts) < 2 || !strings.HasPrefix(release, "go") {
+		return ""
+	}
+	for _, p := range parts[:2] {
+		if _, err := strconv.Atoi(p); err != nil {
+			return ""
+		}
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// jenSums returns the lines of jennifer of the go.sum in dir
+func jenSums(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		return ""
+	}
+	var ret string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, jenPath+" ") {
+			ret += line + "\n"
+		}
+	}
+	return ret
+}
+
+// moduleRoot returns the root of the module containing the working directory
+// or an empty string
+func moduleRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -1,6 +1,11 @@
 package run
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,3 +23,26 @@ func TestRunExec(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "Hello World!\n", *out)
 }
+
+func TestGoVersion(t *testing.T) {
+	assert.Equal(t, "1.22", goVersion("go1.22.5"))
+	assert.Equal(t, "1.21", goVersion("go1.21"))
+	assert.Equal(t, "", goVersion("devel go1.23-abc"))
+}
+
+func TestModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tojen")
+	if err != nil {
+		assert.Nil(t, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.Nil(t, Module(dir, "m"))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "\ngo "+goVersion(runtime.Version())+"\n")
+	// tidy keeps the sums of the packages the module imports, none here
+	if sum, err := ioutil.ReadFile(filepath.Join(dir, "go.sum")); err == nil {
+		assert.Equal(t, "", strings.TrimSpace(string(sum)))
+	}
+}